@@ -17,10 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
-	"github.com/rkosegi/jdownloader-go/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
 )
 
 const (
@@ -29,13 +30,14 @@ const (
 )
 
 func main() {
+	ctx := context.Background()
 	c := jdownloader.NewClient(mail, password, slog.Default())
-	devs, err := c.ListDevices()
+	devs, err := c.ListDevices(ctx)
 	if err != nil {
 		panic(err)
 	}
 	for _, dev := range *devs {
 		fmt.Printf("Device: %s\n", dev.Name)
 	}
-	_ = c.Disconnect()
+	_ = c.Disconnect(ctx)
 }
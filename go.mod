@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-module github.com/rkosegi/jdownloader-go
+module github.com/rkosegi/jdownloader-go/v2
 
 go 1.26
 
@@ -0,0 +1,64 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"fmt"
+)
+
+// CleanupAction selects which links are removed by cleanup
+type CleanupAction string
+
+const (
+	CleanupActionDeleteAll      = CleanupAction("DELETE_ALL")
+	CleanupActionDeleteDisabled = CleanupAction("DELETE_DISABLED")
+	CleanupActionDeleteFailed   = CleanupAction("DELETE_FAILED")
+	CleanupActionDeleteFinished = CleanupAction("DELETE_FINISHED")
+	CleanupActionDeleteOffline  = CleanupAction("DELETE_OFFLINE")
+	CleanupActionDeleteDupe     = CleanupAction("DELETE_DUPE")
+	CleanupActionDeleteMode     = CleanupAction("DELETE_MODE")
+)
+
+// CleanupMode controls what happens with downloaded files of removed links
+type CleanupMode string
+
+const (
+	CleanupModeRemoveLinksOnly            = CleanupMode("REMOVE_LINKS_ONLY")
+	CleanupModeRemoveLinksAndDeleteFiles  = CleanupMode("REMOVE_LINKS_AND_DELETE_FILES")
+	CleanupModeRemoveLinksAndRecycleFiles = CleanupMode("REMOVE_LINKS_AND_RECYCLE_FILES")
+)
+
+// CleanupSelection controls how given link and package UUIDs are interpreted
+type CleanupSelection string
+
+const (
+	// CleanupSelectionAll ignores given UUIDs, whole list is cleaned up
+	CleanupSelectionAll = CleanupSelection("ALL")
+	// CleanupSelectionSelected cleans up only given links and packages
+	CleanupSelectionSelected = CleanupSelection("SELECTED")
+	// CleanupSelectionUnselected cleans up everything except given links and packages
+	CleanupSelectionUnselected = CleanupSelection("UNSELECTED")
+	// CleanupSelectionNone matches no link or package, so cleanup has no effect
+	CleanupSelectionNone = CleanupSelection("NONE")
+)
+
+func cleanup(ctx context.Context, prefix string, d *jDevice, linkIds []int64, packageIds []int64,
+	action CleanupAction, mode CleanupMode, selection CleanupSelection) error {
+	_, err := d.doDevice(ctx, fmt.Sprintf("/%s/cleanup", prefix), false, uuids(linkIds), uuids(packageIds), action, mode, selection)
+	return err
+}
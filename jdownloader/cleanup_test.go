@@ -0,0 +1,75 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanup(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	for _, prefix := range []string{"/downloadsV2", "/linkgrabberv2"} {
+		d.Handle(prefix+"/cleanup", func(params []json.RawMessage) (interface{}, error) {
+			var linkIds, packageIds []int64
+			var action, mode, selection string
+			assert.NoError(t, jdtest.DecodeParam(params[0], &linkIds))
+			assert.NoError(t, jdtest.DecodeParam(params[1], &packageIds))
+			assert.NoError(t, jdtest.DecodeParam(params[2], &action))
+			assert.NoError(t, jdtest.DecodeParam(params[3], &mode))
+			assert.NoError(t, jdtest.DecodeParam(params[4], &selection))
+			assert.NotNil(t, linkIds)
+			assert.Equal(t, []int64{5}, packageIds)
+			assert.Equal(t, "DELETE_FINISHED", action)
+			assert.Equal(t, "REMOVE_LINKS_ONLY", mode)
+			assert.Equal(t, "SELECTED", selection)
+			return nil, nil
+		})
+	}
+	assert.NoError(t, dev.Downloader().Cleanup(t.Context(), nil, []int64{5}, jdownloader.CleanupActionDeleteFinished,
+		jdownloader.CleanupModeRemoveLinksOnly, jdownloader.CleanupSelectionSelected))
+	assert.NoError(t, dev.LinkGrabber().Cleanup(t.Context(), nil, []int64{5}, jdownloader.CleanupActionDeleteFinished,
+		jdownloader.CleanupModeRemoveLinksOnly, jdownloader.CleanupSelectionSelected))
+	assert.Equal(t, 1, s.Calls("/downloadsV2/cleanup"))
+	assert.Equal(t, 1, s.Calls("/linkgrabberv2/cleanup"))
+}
+
+func TestMockCleanup(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	l1, l2, l3 := watchedLink(1, 10), watchedLink(2, 10), watchedLink(3, 11)
+	l1.Finished, l3.Finished = ptr(true), ptr(true)
+	l1.Url, l2.Url, l3.Url = ptr("https://a.tld/1"), ptr("https://a.tld/2"), ptr("https://a.tld/2")
+	dev.SetLinks(&[]jdownloader.DownloadLink{l1, l2, l3})
+	dl := dev.Downloader()
+
+	assert.NoError(t, dl.Cleanup(ctx, nil, []int64{11}, jdownloader.CleanupActionDeleteFinished,
+		jdownloader.CleanupModeRemoveLinksOnly, jdownloader.CleanupSelectionUnselected))
+	links, _ := dl.Links(ctx)
+	assert.Len(t, *links, 2)
+	assert.Equal(t, int64(2), *(*links)[0].Uuid)
+
+	assert.NoError(t, dl.Cleanup(ctx, nil, nil, jdownloader.CleanupActionDeleteDupe,
+		jdownloader.CleanupModeRemoveLinksOnly, jdownloader.CleanupSelectionAll))
+	links, _ = dl.Links(ctx)
+	assert.Len(t, *links, 1)
+	assert.Equal(t, int64(2), *(*links)[0].Uuid)
+}
@@ -18,8 +18,10 @@ package jdownloader
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -48,25 +50,58 @@ type sessionInfo struct {
 	ResponseIdentifier
 }
 
-type jDownloaderClient struct {
-	connected             bool
-	email                 string
-	counter               int64
-	appKey                string
-	loginSecret           [32]byte
-	deviceSecret          [32]byte
-	serverEncryptionToken [32]byte
-	deviceEncryptionToken [32]byte
-	client                http.Client
+// apiCall describes single HTTP request made against API server or device
+type apiCall struct {
+	source ErrorSource
+	// endpoint overrides client's API endpoint, used for direct connection to device
+	endpoint string
+	action   string
+	path     string
+	method   string
+	data     []byte
+	key      [32]byte
+	rid      int64
+}
+
+// sessionTokens holds tokens of current session.
+// Value is replaced as a whole whenever tokens are rotated, so it can be safely used after being read.
+type sessionTokens struct {
 	sessionToken          string
 	regainToken           string
-	lock                  sync.Mutex
-	log                   *slog.Logger
-	configHash            string
-	lastCall              time.Time
-	lastCallLock          sync.Mutex
-	endpoint              string
-	afterCallFn           func(error, time.Duration)
+	serverEncryptionToken [32]byte
+	deviceEncryptionToken [32]byte
+}
+
+type jDownloaderClient struct {
+	connected    bool
+	email        string
+	counter      int64
+	appKey       string
+	loginSecret  [32]byte
+	deviceSecret [32]byte
+	tokens       sessionTokens
+	// tokenLock guards tokens and connected
+	tokenLock sync.RWMutex
+	client    *http.Client
+	// ownTransport is true when client's transport was created by this client and can be modified
+	ownTransport bool
+	headers      http.Header
+	log          *slog.Logger
+	// sessionLock serializes session establishment (connect, reconnect and session recovery)
+	sessionLock      sync.Mutex
+	configHash       string
+	lastCall         time.Time
+	lastCallLock     sync.Mutex
+	endpoint         string
+	afterCallFn      func(error, time.Duration)
+	directConnection bool
+	// directs holds state of direct connection per device id, so it survives between Device calls
+	directs     map[string]*directConnection
+	directsLock sync.Mutex
+	// eventStreams holds live event subscriptions by their channel
+	eventStreams     map[<-chan Event]*eventStream
+	eventStreamsLock sync.Mutex
+	retryPolicy      *RetryPolicy
 }
 
 type ClientOption func(c *jDownloaderClient)
@@ -89,6 +124,14 @@ func ClientOptionTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// ClientOptionDirectConnection enables sending device requests directly to device's LAN address,
+// when it's reachable. Requests are relayed via API server when direct connection is not possible.
+func ClientOptionDirectConnection(enabled bool) ClientOption {
+	return func(c *jDownloaderClient) {
+		c.directConnection = enabled
+	}
+}
+
 func ClientOptionAppKey(app string) ClientOption {
 	return func(c *jDownloaderClient) {
 		c.appKey = app
@@ -100,11 +143,13 @@ func (j *jDownloaderClient) ConfigHash() string {
 }
 
 func (j *jDownloaderClient) IsConnected() bool {
+	j.tokenLock.RLock()
+	defer j.tokenLock.RUnlock()
 	return j.connected
 }
 
-func (j *jDownloaderClient) Device(name string) (_ Device, err error) {
-	dl, err := j.ListDevices()
+func (j *jDownloaderClient) Device(ctx context.Context, name string) (_ Device, err error) {
+	dl, err := j.ListDevices(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -118,54 +163,82 @@ func (j *jDownloaderClient) Device(name string) (_ Device, err error) {
 	if dev == nil {
 		return nil, fmt.Errorf("no such device: %s", name)
 	}
-	return &jDevice{
+	d := &jDevice{
 		id:     dev.Id,
 		name:   dev.Name,
 		log:    j.log.With("device", dev.Name),
 		impl:   j,
 		status: dev.Status,
-	}, nil
+	}
+	if j.directConnection {
+		d.direct = j.directConnectionOf(dev.Id)
+	}
+	return d, nil
 }
 
-func (j *jDownloaderClient) Connect() (err error) {
-	j.serverEncryptionToken = j.loginSecret
-	j.deviceEncryptionToken = j.deviceSecret
+func (j *jDownloaderClient) addEventStream(ch <-chan Event, stream *eventStream) {
+	j.eventStreamsLock.Lock()
+	defer j.eventStreamsLock.Unlock()
+	if j.eventStreams == nil {
+		j.eventStreams = make(map[<-chan Event]*eventStream)
+	}
+	j.eventStreams[ch] = stream
+}
+
+func (j *jDownloaderClient) eventStream(ch <-chan Event) *eventStream {
+	j.eventStreamsLock.Lock()
+	defer j.eventStreamsLock.Unlock()
+	return j.eventStreams[ch]
+}
+
+func (j *jDownloaderClient) removeEventStream(ch <-chan Event) {
+	j.eventStreamsLock.Lock()
+	defer j.eventStreamsLock.Unlock()
+	delete(j.eventStreams, ch)
+}
+
+func (j *jDownloaderClient) directConnectionOf(id string) *directConnection {
+	j.directsLock.Lock()
+	defer j.directsLock.Unlock()
+	if j.directs == nil {
+		j.directs = make(map[string]*directConnection)
+	}
+	dc, found := j.directs[id]
+	if !found {
+		dc = &directConnection{}
+		j.directs[id] = dc
+	}
+	return dc
+}
+
+func (j *jDownloaderClient) Connect(ctx context.Context) error {
+	j.sessionLock.Lock()
+	defer j.sessionLock.Unlock()
+	return j.connect(ctx)
+}
+
+func (j *jDownloaderClient) connect(ctx context.Context) error {
+	j.tokenLock.Lock()
 	j.connected = false
+	j.tokenLock.Unlock()
 
-	data, err := j.doServer("/my/connect", http.MethodPost, []string{
+	return j.establishSession(ctx, j.serverCall("/my/connect", http.MethodPost, []string{
 		qp("email", strings.ToLower(j.email)),
 		qp("appkey", strings.ToLower(j.appKey)),
-	}, nil, j.loginSecret)
-	if err != nil {
-		return fmt.Errorf("API doServer failed: %v", err)
-	}
-	session := &sessionInfo{}
-	err = json.Unmarshal(data, session)
-	if err != nil {
-		return fmt.Errorf("invalid payload received from server: %v", err)
-	}
-	if j.currentRid() != session.ResponseID {
-		return fmt.Errorf("mismatched RID, expected: %d, actual: %d", j.currentRid(), session.ResponseID)
-	}
-	newToken, err := hex.DecodeString(session.SessionToken)
-	if err != nil {
-		return fmt.Errorf("unable to decode session token from response: %v", err)
-	}
-	j.sessionToken = session.SessionToken
-	j.regainToken = session.RegainToken
-	j.updateTokens(newToken)
-	j.connected = true
-	return nil
+	}, nil, j.loginSecret), sessionTokens{
+		serverEncryptionToken: j.loginSecret,
+		deviceEncryptionToken: j.deviceSecret,
+	})
 }
 
-func (j *jDownloaderClient) ListDevices() (_ *[]DeviceInfo, err error) {
-	err = j.reconnectIfNecessary()
-	if err != nil {
-		return nil, err
-	}
-	data, err := j.doServer("/my/listdevices", http.MethodGet, []string{
-		qp(paramSessionToken, j.sessionToken),
-	}, nil, j.serverEncryptionToken)
+func (j *jDownloaderClient) ListDevices(ctx context.Context) (_ *[]DeviceInfo, err error) {
+	data, err := j.withRetry(ctx, "/my/listdevices", func() ([]byte, error) {
+		return j.withSession(ctx, func(s sessionTokens) ([]byte, error) {
+			return j.doServer(ctx, "/my/listdevices", http.MethodGet, []string{
+				qp(paramSessionToken, s.sessionToken),
+			}, nil, s.serverEncryptionToken)
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -177,11 +250,37 @@ func (j *jDownloaderClient) ListDevices() (_ *[]DeviceInfo, err error) {
 	return &dl.List, nil
 }
 
-func (j *jDownloaderClient) Reconnect() (err error) {
-	data, err := j.doServer("/my/reconnect", http.MethodGet, []string{
-		qp(paramSessionToken, j.sessionToken),
-		qp("regaintoken", j.regainToken),
-	}, nil, j.serverEncryptionToken)
+func (j *jDownloaderClient) Reconnect(ctx context.Context) error {
+	j.sessionLock.Lock()
+	defer j.sessionLock.Unlock()
+	return j.reconnect(ctx)
+}
+
+func (j *jDownloaderClient) reconnect(ctx context.Context) error {
+	s := j.session()
+	return j.establishSession(ctx, j.serverCall("/my/reconnect", http.MethodGet, []string{
+		qp(paramSessionToken, s.sessionToken),
+		qp("regaintoken", s.regainToken),
+	}, nil, s.serverEncryptionToken), s)
+}
+
+func (j *jDownloaderClient) Disconnect(ctx context.Context) error {
+	s := j.session()
+	_, err := j.doServer(ctx, "/my/disconnect", http.MethodPost, []string{
+		qp(paramSessionToken, s.sessionToken),
+	}, nil, s.serverEncryptionToken)
+	if err == nil {
+		j.tokenLock.Lock()
+		j.connected = false
+		j.tokenLock.Unlock()
+	}
+	return err
+}
+
+// establishSession performs connect or reconnect call and derives new session tokens from existing ones.
+// This is the only place where session tokens are rotated.
+func (j *jDownloaderClient) establishSession(ctx context.Context, call *apiCall, existing sessionTokens) error {
+	data, err := j.do(ctx, call)
 	if err != nil {
 		return err
 	}
@@ -190,84 +289,157 @@ func (j *jDownloaderClient) Reconnect() (err error) {
 	if err != nil {
 		return fmt.Errorf("invalid payload received from server: %v", err)
 	}
-	if j.currentRid() != session.ResponseID {
-		return fmt.Errorf("mismatched RID, expected: %d, actual: %d", j.currentRid(), session.ResponseID)
+	if call.rid != session.ResponseID {
+		return fmt.Errorf("mismatched RID, expected: %d, actual: %d", call.rid, session.ResponseID)
 	}
 	newToken, err := hex.DecodeString(session.SessionToken)
 	if err != nil {
 		return fmt.Errorf("unable to decode session token from response: %v", err)
 	}
-	j.sessionToken = session.SessionToken
-	j.regainToken = session.RegainToken
-	j.updateTokens(newToken)
+	j.tokenLock.Lock()
+	defer j.tokenLock.Unlock()
+	j.tokens = sessionTokens{
+		sessionToken:          session.SessionToken,
+		regainToken:           session.RegainToken,
+		serverEncryptionToken: updateToken(newToken, existing.serverEncryptionToken),
+		deviceEncryptionToken: updateToken(newToken, existing.deviceEncryptionToken),
+	}
 	j.connected = true
 	return nil
 }
 
-func (j *jDownloaderClient) Disconnect() error {
-	_, err := j.doServer("/my/disconnect", http.MethodPost, []string{
-		qp(paramSessionToken, j.sessionToken),
-	}, nil, j.serverEncryptionToken)
-	return err
+// session gets tokens of current session
+func (j *jDownloaderClient) session() sessionTokens {
+	j.tokenLock.RLock()
+	defer j.tokenLock.RUnlock()
+	return j.tokens
 }
 
-func (j *jDownloaderClient) updateTokens(newToken []byte) {
-	j.serverEncryptionToken = updateToken(newToken, j.serverEncryptionToken)
-	j.deviceEncryptionToken = updateToken(newToken, j.deviceEncryptionToken)
+// reconnectIfNecessary performs full login, unless client is already connected
+func (j *jDownloaderClient) reconnectIfNecessary(ctx context.Context) error {
+	if j.IsConnected() {
+		return nil
+	}
+	j.sessionLock.Lock()
+	defer j.sessionLock.Unlock()
+	if !j.IsConnected() {
+		return j.connect(ctx)
+	}
+	return nil
 }
 
-func (j *jDownloaderClient) reconnectIfNecessary() error {
-	j.lastCallLock.Lock()
-	defer j.lastCallLock.Unlock()
-	if !j.connected || time.Since(j.lastCall).Seconds() > 30 {
-		j.lastCall = time.Now()
-		return j.Connect()
+// withSession ensures that client is connected, then invokes fn with tokens of current session.
+// If fn fails because session is no longer valid, session is recovered and fn is invoked once again with new tokens.
+func (j *jDownloaderClient) withSession(ctx context.Context, fn func(sessionTokens) ([]byte, error)) ([]byte, error) {
+	if err := j.reconnectIfNecessary(ctx); err != nil {
+		return nil, err
 	}
-	return nil
+	s := j.session()
+	data, err := fn(s)
+	if !errors.Is(err, ErrTokenInvalid) {
+		return data, err
+	}
+	j.log.Info("session token is no longer valid, trying to recover session")
+	if err = j.recoverSession(ctx, s.sessionToken); err != nil {
+		return nil, err
+	}
+	return fn(j.session())
 }
 
-func (j *jDownloaderClient) do(path string, method string, data []byte, key [32]byte) (_ []byte, err error) {
-	defer j.onApiDone(err, time.Now())
-	j.lock.Lock()
-	defer j.lock.Unlock()
-	uri := fmt.Sprintf("%s%s", j.endpoint, path)
-	var resp *http.Response
-	j.log.Debug("Request", "method", method, "uri", uri, "rid", j.currentRid())
-	if http.MethodGet == method {
-		resp, err = j.client.Get(uri)
-	} else {
-		if data != nil {
-			resp, err = j.client.Post(uri, mediaType, bytes.NewBuffer(data))
-		} else {
-			resp, err = j.client.Post(uri, mediaType, nil)
+// recoverSession tries to regain session using regain token and falls back to full login if that fails.
+// If session was already replaced by concurrent caller in the meantime, nothing is done.
+func (j *jDownloaderClient) recoverSession(ctx context.Context, staleToken string) error {
+	j.sessionLock.Lock()
+	defer j.sessionLock.Unlock()
+	s := j.session()
+	if s.sessionToken != staleToken {
+		return nil
+	}
+	if s.regainToken != "" {
+		err := j.reconnect(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
 		}
+		j.log.Warn("unable to regain session, falling back to full login", "error", err)
 	}
+	return j.connect(ctx)
+}
+
+func (j *jDownloaderClient) do(ctx context.Context, call *apiCall) (_ []byte, err error) {
+	defer func(start time.Time) {
+		j.onApiDone(err, start)
+	}(time.Now())
+	endpoint := j.endpoint
+	if call.endpoint != "" {
+		endpoint = call.endpoint
+	}
+	uri := fmt.Sprintf("%s%s", endpoint, call.path)
+	var body io.Reader
+	if http.MethodGet != call.method && call.data != nil {
+		body = bytes.NewBuffer(call.data)
+	}
+	req, err := http.NewRequestWithContext(ctx, call.method, uri, body)
 	if err != nil {
 		return nil, err
 	}
-	j.log.Debug("Response", "status", resp.StatusCode, "rid", j.currentRid())
+	for name, values := range j.headers {
+		req.Header[name] = values
+	}
+	if http.MethodGet != call.method {
+		req.Header.Set("Content-Type", mediaType)
+	}
+	j.log.Debug("Request", "method", call.method, "uri", uri, "rid", call.rid)
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	j.log.Debug("Response", "status", resp.StatusCode, "rid", call.rid)
 	defer bodycloser(resp.Body, j.log)
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to fully consume response body: %v", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		e := parseError(body, key, j.log)
-		return nil, fmt.Errorf("API doServer failed: %v", e)
+		return nil, newAPIError(call, resp.StatusCode, respBody, j.log)
 	} else {
-		return decode(body, key)
+		j.touch()
+		return decode(respBody, call.key)
 	}
 }
 
-func (j *jDownloaderClient) doServer(path string, method string, args []string, data []byte, key [32]byte) (_ []byte, err error) {
+func (j *jDownloaderClient) doServer(ctx context.Context, path string, method string, args []string, data []byte, key [32]byte) (_ []byte, err error) {
+	return j.do(ctx, j.serverCall(path, method, args, data, key))
+}
+
+// serverCall creates signed call to API server using next RID
+func (j *jDownloaderClient) serverCall(path string, method string, args []string, data []byte, key [32]byte) *apiCall {
 	if args == nil {
 		args = make([]string, 0)
 	}
-	args = append(args, qp("rid", strconv.FormatInt(j.nextRid(), 10)))
+	rid := j.nextRid()
+	args = append(args, qp("rid", strconv.FormatInt(rid, 10)))
 	uri := strings.Join(args, "&")
 	uri = fmt.Sprintf("%s?%s", path, uri)
 	uri = fmt.Sprintf("%s&signature=%s", uri, sign(uri, key[:]))
-	return j.do(uri, method, data, key)
+	return &apiCall{
+		source: ErrorSourceServer,
+		action: path,
+		path:   uri,
+		method: method,
+		data:   data,
+		key:    key,
+		rid:    rid,
+	}
+}
+
+// touch records time of last successful API call
+func (j *jDownloaderClient) touch() {
+	j.lastCallLock.Lock()
+	defer j.lastCallLock.Unlock()
+	j.lastCall = time.Now()
 }
 
 func (j *jDownloaderClient) nextRid() int64 {
@@ -275,7 +447,7 @@ func (j *jDownloaderClient) nextRid() int64 {
 }
 
 func (j *jDownloaderClient) currentRid() int64 {
-	return j.counter
+	return atomic.LoadInt64(&j.counter)
 }
 
 func (j *jDownloaderClient) onApiDone(err error, start time.Time) {
@@ -17,148 +17,1586 @@ limitations under the License.
 package jdownloader
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
 )
 
+const (
+	mockStateIdle    = "IDLE"
+	mockStateRunning = "RUNNING"
+	mockStatePause   = "PAUSE"
+	mockStateStopped = "STOPPED"
+)
+
+// MockClient is in-memory implementation of JdClient, intended to be used in tests
 type MockClient struct {
+	lock      sync.Mutex
 	devs      *[]DeviceInfo
+	mocks     map[string]*MockDevice
 	connected bool
 }
 
 func (m *MockClient) SetDevices(devs *[]DeviceInfo) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	m.devs = devs
 }
 
-func (m *MockClient) Connect() error {
+func (m *MockClient) Connect(context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	m.connected = true
 	return nil
 }
 
 func (m *MockClient) IsConnected() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	return m.connected
 }
 
-func (m *MockClient) Reconnect() error {
+func (m *MockClient) Reconnect(context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.connected = true
 	return nil
 }
 
-func (m *MockClient) Disconnect() error {
+func (m *MockClient) Disconnect(context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	m.connected = false
 	return nil
 }
 
-func (m *MockClient) ListDevices() (*[]DeviceInfo, error) {
-	return m.devs, nil
+func (m *MockClient) ListDevices(context.Context) (*[]DeviceInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	devs := slices.Clone(*m.devs)
+	return &devs, nil
+}
+
+// Device gets mock device by name. Same instance is returned for the same name, so that state is preserved.
+func (m *MockClient) Device(_ context.Context, name string) (Device, error) {
+	d := m.MockDevice(name)
+	if d == nil {
+		return nil, fmt.Errorf("no such device: %s", name)
+	}
+	return d, nil
 }
 
-func (m *MockClient) Device(name string) (Device, error) {
+// MockDevice gets mock device by name, or nil if there is no such device.
+// It can be used to set up or inspect device's state.
+func (m *MockClient) MockDevice(name string) *MockDevice {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if d, ok := m.mocks[name]; ok {
+		return d
+	}
 	for _, d := range *m.devs {
 		if d.Name == name {
-			return &MockDevice{
-				id: name,
-			}, nil
+			md := newMockDevice(d)
+			m.mocks[name] = md
+			return md
 		}
 	}
-	return nil, fmt.Errorf("no such device: %s", name)
+	return nil
 }
 
 func (m *MockClient) ConfigHash() string {
 	return "123"
 }
 
+func (m *MockClient) SessionState() (*SessionState, error) {
+	if !m.IsConnected() {
+		return nil, errors.New("client is not connected")
+	}
+	return &SessionState{}, nil
+}
+
+func (m *MockClient) ExportSessionState(bool) ([]byte, error) {
+	if _, err := m.SessionState(); err != nil {
+		return nil, err
+	}
+	return []byte("{}"), nil
+}
+
 func NewMockClient() *MockClient {
 	return &MockClient{
-		devs: &[]DeviceInfo{},
+		devs:  &[]DeviceInfo{},
+		mocks: map[string]*MockDevice{},
 	}
 }
 
+// MockDevice is in-memory device. Its downloader and link grabber share single state.
 type MockDevice struct {
-	Device
-	links []DownloadLink
-	id    string
+	lock              sync.Mutex
+	info              DeviceInfo
+	state             string
+	speed             float64
+	nextId            int64
+	links             []DownloadLink
+	packages          []DownloadPackage
+	crawledLinks      []CrawledLink
+	crawledPkgs       []CrawledPackage
+	subscribers       []*mockSubscriber
+	stopMark          int64
+	variants          map[int64][]LinkVariant
+	crawlerJobs       map[int64][]int64
+	downloadStructure mockStructure
+	crawledStructure  mockStructure
+}
+
+// mockStructure tracks structural changes of list using its fingerprint
+type mockStructure struct {
+	fingerprint string
+	counter     int64
+}
+
+// changeCounter bumps counter whenever fingerprint changes. Caller must hold lock.
+func (s *mockStructure) changeCounter(fingerprint string, old int64) int64 {
+	if fingerprint != s.fingerprint {
+		s.fingerprint = fingerprint
+		s.counter++
+	}
+	if s.counter == old {
+		return -1
+	}
+	return s.counter
+}
+
+type mockSubscriber struct {
+	ctx    context.Context
+	ch     chan Event
+	out    <-chan Event
+	params *EventSubscribeParams
+}
+
+func newMockDevice(info DeviceInfo) *MockDevice {
+	return &MockDevice{
+		info:   info,
+		state:  mockStateIdle,
+		nextId: 1000,
+	}
 }
 
 func (d *MockDevice) LinkGrabber() LinkGrabber {
-	// TODO implement me
-	panic("implement me")
+	return &MockLinkGrabber{dev: d}
 }
 
 func (d *MockDevice) Downloader() Downloader {
-	return &MockDownloader{
-		dev: *d,
-	}
+	return &MockDownloader{dev: d}
+}
+
+func (d *MockDevice) Events() Events {
+	return &MockEvents{dev: d}
 }
 
 func (d *MockDevice) Name() string {
-	// TODO implement me
-	panic("implement me")
+	return d.info.Name
 }
 
 func (d *MockDevice) Id() string {
-	return d.id
+	if d.info.Id == "" {
+		return d.info.Name
+	}
+	return d.info.Id
 }
 
 func (d *MockDevice) Status() string {
-	return "UNKNOWN"
+	if d.info.Status == "" {
+		return "UNKNOWN"
+	}
+	return d.info.Status
 }
 
-func (d *MockDevice) ConnectionInfo() (*DirectConnectionInfo, error) {
+func (d *MockDevice) ConnectionInfo(context.Context) (*DirectConnectionInfo, error) {
 	return &DirectConnectionInfo{}, nil
 }
 
-func (d *MockDevice) Packages(...LinkGrabberQueryPackagesOptions) (*[]DownloadPackage, error) {
-	// TODO implement me
-	panic("implement me")
+// Poll computes snapshot from current state of mock device
+func (d *MockDevice) Poll(_ context.Context, options ...PollOptions) (*PollSnapshot, error) {
+	params := &PollParams{}
+	if len(options) == 0 {
+		options = append(options, DefaultPollOptions())
+	}
+	for _, opt := range options {
+		opt(params)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	snapshot := &PollSnapshot{}
+	if params.JdState != nil && *params.JdState {
+		state := d.state
+		snapshot.JdState = &state
+	}
+	if params.LinkGrabberState != nil && *params.LinkGrabberState {
+		state := mockStateIdle
+		snapshot.LinkGrabberState = &state
+	}
+	if params.AggregatedNumbers != nil && *params.AggregatedNumbers {
+		var loaded, total int64
+		var running int
+		for _, l := range d.links {
+			if l.BytesLoaded != nil {
+				loaded += *l.BytesLoaded
+			}
+			if l.BytesTotal != nil {
+				total += *l.BytesTotal
+			}
+			if l.Running != nil && *l.Running {
+				running++
+			}
+		}
+		speed := 0.0
+		if d.state == mockStateRunning {
+			speed = d.speed
+		}
+		linkCount, packageCount := len(d.links), len(d.packages)
+		snapshot.AggregatedNumbers = &AggregatedNumbers{
+			BytesLoaded:   &loaded,
+			BytesTotal:    &total,
+			DownloadSpeed: &speed,
+			LinkCount:     &linkCount,
+			PackageCount:  &packageCount,
+			RunningLinks:  &running,
+		}
+	}
+	return snapshot, nil
 }
 
-func (d *MockDevice) Links(...DownloadQueryLinksOptions) (*[]DownloadLink, error) {
-	// TODO implement me
-	panic("implement me")
+// SetLinks replaces content of download list. Packages referenced by links are created when missing.
+// Links without UUID get new one assigned.
+func (d *MockDevice) SetLinks(links *[]DownloadLink) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.links = deepCopy(*links)
+	for i, l := range d.links {
+		if l.Uuid == nil {
+			uuid := d.newId()
+			d.links[i].Uuid = &uuid
+		}
+		if l.PackageUuid != nil && d.downloadPackage(*l.PackageUuid) == nil {
+			uuid := *l.PackageUuid
+			d.packages = append(d.packages, DownloadPackage{Uuid: &uuid})
+		}
+	}
 }
 
-func (d *MockDevice) SetLinks(links *[]DownloadLink) {
-	d.links = *links
+// SetPackages replaces packages in download list. Packages without UUID get new one assigned.
+func (d *MockDevice) SetPackages(packages *[]DownloadPackage) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.packages = deepCopy(*packages)
+	for i, p := range d.packages {
+		if p.Uuid == nil {
+			uuid := d.newId()
+			d.packages[i].Uuid = &uuid
+		}
+	}
+}
+
+// SetSpeed sets download speed reported while download is running
+func (d *MockDevice) SetSpeed(speed float64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.speed = speed
+}
+
+// UpdateLink applies fn to download link with given UUID, e.g. to simulate download progress
+func (d *MockDevice) UpdateLink(uuid int64, fn func(link *DownloadLink)) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := range d.links {
+		if d.links[i].Uuid != nil && *d.links[i].Uuid == uuid {
+			fn(&d.links[i])
+			return nil
+		}
+	}
+	return fmt.Errorf("no such link: %d", uuid)
+}
+
+// UpdateCrawledLink applies function to link in link grabber
+func (d *MockDevice) UpdateCrawledLink(uuid int64, fn func(link *CrawledLink)) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for i := range d.crawledLinks {
+		if d.crawledLinks[i].Uuid != nil && *d.crawledLinks[i].Uuid == uuid {
+			fn(&d.crawledLinks[i])
+			return nil
+		}
+	}
+	return fmt.Errorf("no such link: %d", uuid)
+}
+
+// SetVariants sets variants offered for crawled link. First variant is selected.
+func (d *MockDevice) SetVariants(uuid int64, variants []LinkVariant) error {
+	return d.UpdateCrawledLink(uuid, func(link *CrawledLink) {
+		if d.variants == nil {
+			d.variants = make(map[int64][]LinkVariant)
+		}
+		d.variants[uuid] = variants
+		hasVariants := len(variants) > 0
+		link.Variants = &hasVariants
+		link.Variant = nil
+		if hasVariants {
+			link.Variant = &variants[0]
+		}
+	})
+}
+
+// Emit delivers event to all active subscribers whose subscriptions match event's publisher.
+// It blocks until event is delivered or subscriber's context is cancelled.
+func (d *MockDevice) Emit(ev Event) {
+	ev.Type = eventTypeOf(ev.Publisher)
+	d.lock.Lock()
+	d.subscribers = slices.DeleteFunc(d.subscribers, func(s *mockSubscriber) bool {
+		return s.ctx.Err() != nil
+	})
+	subs := slices.DeleteFunc(slices.Clone(d.subscribers), func(s *mockSubscriber) bool {
+		return !s.params.matches(ev)
+	})
+	d.lock.Unlock()
+	for _, s := range subs {
+		select {
+		case s.ch <- ev:
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+func (d *MockDevice) newId() int64 {
+	d.nextId++
+	return d.nextId
+}
+
+// downloadPackage finds package in download list. Caller must hold lock.
+func (d *MockDevice) downloadPackage(uuid int64) *DownloadPackage {
+	for i := range d.packages {
+		if d.packages[i].Uuid != nil && *d.packages[i].Uuid == uuid {
+			return &d.packages[i]
+		}
+	}
+	return nil
+}
+
+// moveToDownloadList moves crawled links and packages into download list. Caller must hold lock.
+func (d *MockDevice) moveToDownloadList(linkIds []int64, packageIds []int64) {
+	moved := slices.DeleteFunc(slices.Clone(d.crawledLinks), func(l CrawledLink) bool {
+		return !matches(l.Uuid, linkIds) && !matches(l.PackageUuid, packageIds)
+	})
+	for _, cl := range moved {
+		pkg := d.downloadPackage(*cl.PackageUuid)
+		if pkg == nil {
+			for _, cp := range d.crawledPkgs {
+				if *cp.Uuid == *cl.PackageUuid {
+					d.packages = append(d.packages, DownloadPackage{
+						Uuid:    cp.Uuid,
+						Name:    cp.Name,
+						SaveTo:  cp.SaveTo,
+						Enabled: ptrTo(true),
+					})
+				}
+			}
+		}
+		d.links = append(d.links, DownloadLink{
+			AddedDate:        new(int64),
+			BytesTotal:       mockInt64(cl.BytesTotal),
+			BytesLoaded:      new(int64),
+			Comment:          cl.Comment,
+			DownloadPassword: cl.DownloadPassword,
+			Enabled:          cl.Enabled,
+			Finished:         new(bool),
+			Host:             cl.Host,
+			Name:             cl.Name,
+			PackageUuid:      cl.PackageUuid,
+			Priority:         cl.Priority,
+			Running:          new(bool),
+			Status:           new(string),
+			Url:              cl.Url,
+			Uuid:             cl.Uuid,
+		})
+	}
+	d.removeCrawled(linkIds, packageIds)
+}
+
+// variant finds variant of crawled link. Caller must hold lock.
+func (d *MockDevice) variant(linkId int64, variantId string) (*LinkVariant, error) {
+	if !slices.ContainsFunc(d.crawledLinks, func(l CrawledLink) bool { return *l.Uuid == linkId }) {
+		return nil, fmt.Errorf("no such link: %d", linkId)
+	}
+	for _, v := range d.variants[linkId] {
+		if v.Id != nil && *v.Id == variantId {
+			return &v, nil
+		}
+	}
+	return nil, fmt.Errorf("no such variant of link %d: %s", linkId, variantId)
+}
+
+// crawledPackage finds package in link grabber. Caller must hold lock.
+func (d *MockDevice) crawledPackage(uuid int64) *CrawledPackage {
+	for i := range d.crawledPkgs {
+		if d.crawledPkgs[i].Uuid != nil && *d.crawledPkgs[i].Uuid == uuid {
+			return &d.crawledPkgs[i]
+		}
+	}
+	return nil
+}
+
+// moveCrawledLinks moves crawled links into package after given link, then removes packages left empty.
+// Caller must hold lock.
+func (d *MockDevice) moveCrawledLinks(linkIds []int64, afterLinkId int64, packageId int64) {
+	moved := slices.DeleteFunc(slices.Clone(d.crawledLinks), func(l CrawledLink) bool {
+		return !matches(l.Uuid, linkIds)
+	})
+	for i := range moved {
+		moved[i].PackageUuid = &packageId
+	}
+	d.crawledLinks = slices.DeleteFunc(d.crawledLinks, func(l CrawledLink) bool {
+		return matches(l.Uuid, linkIds)
+	})
+	pos := slices.IndexFunc(d.crawledLinks, func(l CrawledLink) bool {
+		return *l.Uuid == afterLinkId
+	})
+	d.crawledLinks = slices.Insert(d.crawledLinks, pos+1, moved...)
+	d.crawledPkgs = slices.DeleteFunc(d.crawledPkgs, func(p CrawledPackage) bool {
+		return *p.Uuid != packageId && !slices.ContainsFunc(d.crawledLinks, func(l CrawledLink) bool {
+			return *l.PackageUuid == *p.Uuid
+		})
+	})
+}
+
+// removeDownloads removes links and packages from download list. Caller must hold lock.
+func (d *MockDevice) removeDownloads(linkIds []int64, packageIds []int64) {
+	d.links = slices.DeleteFunc(d.links, func(l DownloadLink) bool {
+		return matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds)
+	})
+	d.packages = slices.DeleteFunc(d.packages, func(p DownloadPackage) bool {
+		return matches(p.Uuid, packageIds) || !slices.ContainsFunc(d.links, func(l DownloadLink) bool {
+			return l.PackageUuid != nil && *l.PackageUuid == *p.Uuid
+		})
+	})
+}
+
+// removeCrawled removes links and packages from link grabber. Caller must hold lock.
+func (d *MockDevice) removeCrawled(linkIds []int64, packageIds []int64) {
+	d.crawledLinks = slices.DeleteFunc(d.crawledLinks, func(l CrawledLink) bool {
+		return matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds)
+	})
+	d.crawledPkgs = slices.DeleteFunc(d.crawledPkgs, func(p CrawledPackage) bool {
+		return matches(p.Uuid, packageIds) || !slices.ContainsFunc(d.crawledLinks, func(l CrawledLink) bool {
+			return *l.PackageUuid == *p.Uuid
+		})
+	})
+}
+
+// moveLinks moves links into package after given link, then removes packages left empty. Caller must hold lock.
+func (d *MockDevice) moveLinks(linkIds []int64, afterLinkId int64, packageId int64) {
+	moved := slices.DeleteFunc(slices.Clone(d.links), func(l DownloadLink) bool {
+		return !matches(l.Uuid, linkIds)
+	})
+	for i := range moved {
+		moved[i].PackageUuid = &packageId
+	}
+	d.links = slices.DeleteFunc(d.links, func(l DownloadLink) bool {
+		return matches(l.Uuid, linkIds)
+	})
+	pos := slices.IndexFunc(d.links, func(l DownloadLink) bool {
+		return l.Uuid != nil && *l.Uuid == afterLinkId
+	})
+	d.links = slices.Insert(d.links, pos+1, moved...)
+	d.packages = slices.DeleteFunc(d.packages, func(p DownloadPackage) bool {
+		return *p.Uuid != packageId && !slices.ContainsFunc(d.links, func(l DownloadLink) bool {
+			return l.PackageUuid != nil && *l.PackageUuid == *p.Uuid
+		})
+	})
 }
 
+// setRunning updates running flag of all unfinished, enabled links. Caller must hold lock.
+func (d *MockDevice) setRunning(running bool) {
+	for i := range d.links {
+		l := &d.links[i]
+		r := running && (l.Enabled == nil || *l.Enabled) && (l.Finished == nil || !*l.Finished)
+		l.Running = &r
+	}
+}
+
+func cleanupSelected(selection CleanupSelection, selected bool) bool {
+	switch selection {
+	case CleanupSelectionAll:
+		return true
+	case CleanupSelectionSelected:
+		return selected
+	case CleanupSelectionUnselected:
+		return !selected
+	}
+	return false
+}
+
+func matches(id *int64, ids []int64) bool {
+	return id != nil && slices.Contains(ids, *id)
+}
+
+// ptrTo allocates new value, so that mock state never shares pointer with other items or package variables
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
+// deepCopy copies items including values referenced by pointer fields,
+// so that callers can't modify mock state through returned items
+func deepCopy[T any](items []T) []T {
+	res := make([]T, 0, len(items))
+	data, err := json.Marshal(items)
+	if err == nil {
+		err = json.Unmarshal(data, &res)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("unable to copy %T: %v", items, err))
+	}
+	return res
+}
+
+func mockInt64(v *uint64) *int64 {
+	if v == nil {
+		return nil
+	}
+	r := int64(*v)
+	return &r
+}
+
+// mockPage applies StartAt and MaxResults query parameters to items
+func mockPage[T any](items []T, startAt *int, maxResults *int) []T {
+	if startAt != nil {
+		items = items[min(max(*startAt, 0), len(items)):]
+	}
+	if maxResults != nil && *maxResults >= 0 && *maxResults < len(items) {
+		items = items[:*maxResults]
+	}
+	return items
+}
+
+// MockDownloader is Downloader backed by MockDevice
 type MockDownloader struct {
-	dev MockDevice
+	dev *MockDevice
 }
 
-func (dw *MockDownloader) Remove([]int64, []int64) error {
+func (dw *MockDownloader) Remove(_ context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	dw.dev.removeDownloads(linkIds, packageIds)
 	return nil
 }
 
-func (dw *MockDownloader) Packages(...LinkGrabberQueryPackagesOptions) (*[]DownloadPackage, error) {
-	// TODO implement me
-	panic("implement me")
+// Packages gets packages in download list. Aggregated fields are computed from package's links.
+func (dw *MockDownloader) Packages(_ context.Context, options ...DownloadQueryPackagesOptions) (*[]DownloadPackage, error) {
+	params := &DownloadQueryPackagesParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	res := make([]DownloadPackage, 0)
+	for _, p := range dw.dev.packages {
+		if params.PackageUUIDs != nil && !matches(p.Uuid, *params.PackageUUIDs) {
+			continue
+		}
+		var childCount int
+		var bytesLoaded, bytesTotal int64
+		var speed float64
+		finished, running := true, false
+		hosts := make([]string, 0)
+		for _, l := range dw.dev.links {
+			if l.PackageUuid == nil || *l.PackageUuid != *p.Uuid {
+				continue
+			}
+			childCount++
+			if l.BytesLoaded != nil {
+				bytesLoaded += *l.BytesLoaded
+			}
+			if l.BytesTotal != nil {
+				bytesTotal += *l.BytesTotal
+			}
+			if l.Speed != nil {
+				speed += *l.Speed
+			}
+			finished = finished && l.Finished != nil && *l.Finished
+			running = running || (l.Running != nil && *l.Running)
+			if l.Host != nil && !slices.Contains(hosts, *l.Host) {
+				hosts = append(hosts, *l.Host)
+			}
+		}
+		p.ChildCount = &childCount
+		p.BytesLoaded = &bytesLoaded
+		p.BytesTotal = &bytesTotal
+		p.Speed = &speed
+		p.Finished = &finished
+		p.Running = &running
+		p.Hosts = &hosts
+		res = append(res, p)
+	}
+	res = deepCopy(mockPage(res, params.StartAt, params.MaxResults))
+	return &res, nil
 }
 
-func (dw *MockDownloader) Links(...DownloadQueryLinksOptions) (*[]DownloadLink, error) {
-	return &dw.dev.links, nil
+func (dw *MockDownloader) Links(_ context.Context, options ...DownloadQueryLinksOptions) (*[]DownloadLink, error) {
+	params := &DownloadQueryLinksParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	res := slices.DeleteFunc(slices.Clone(dw.dev.links), func(l DownloadLink) bool {
+		return params.PackageUUIDs != nil && !matches(l.PackageUuid, *params.PackageUUIDs)
+	})
+	res = deepCopy(mockPage(res, params.StartAt, params.MaxResults))
+	return &res, nil
 }
 
-func (dw *MockDownloader) Start() (bool, error) {
-	// TODO implement me
-	panic("implement me")
+func (dw *MockDownloader) Start(context.Context) (bool, error) {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	if dw.dev.state == mockStateRunning {
+		return false, nil
+	}
+	dw.dev.state = mockStateRunning
+	dw.dev.setRunning(true)
+	return true, nil
 }
 
-func (dw *MockDownloader) Stop() (bool, error) {
-	// TODO implement me
-	panic("implement me")
+func (dw *MockDownloader) Stop(context.Context) (bool, error) {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	if dw.dev.state != mockStateRunning && dw.dev.state != mockStatePause {
+		return false, nil
+	}
+	dw.dev.state = mockStateStopped
+	dw.dev.setRunning(false)
+	return true, nil
 }
 
-func (dw *MockDownloader) Pause() (bool, error) {
-	return false, nil
+func (dw *MockDownloader) Pause(context.Context) (bool, error) {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	if dw.dev.state != mockStateRunning {
+		return false, nil
+	}
+	dw.dev.state = mockStatePause
+	dw.dev.setRunning(false)
+	return true, nil
 }
 
-func (dw *MockDownloader) Speed() (*DownloadSpeedInfo, error) {
-	return nil, nil
+func (dw *MockDownloader) Speed(context.Context) (*DownloadSpeedInfo, error) {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	var speed float64
+	if dw.dev.state == mockStateRunning {
+		speed = dw.dev.speed
+	}
+	return &DownloadSpeedInfo{Speed: &speed}, nil
+}
+
+// Force starts download of given links and packages, regardless of current state
+func (dw *MockDownloader) Force(_ context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	for i := range dw.dev.links {
+		l := &dw.dev.links[i]
+		if (matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds)) && (l.Finished == nil || !*l.Finished) {
+			l.Enabled = ptrTo(true)
+			l.Running = ptrTo(true)
+		}
+	}
+	dw.dev.state = mockStateRunning
+	return nil
+}
+
+func (dw *MockDownloader) State(context.Context) (*DownloadState, error) {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	state := dw.dev.state
+	return &DownloadState{State: &state}, nil
+}
+
+func (dw *MockDownloader) RenamePackage(_ context.Context, id int64, name string) error {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	pkg := dw.dev.downloadPackage(id)
+	if pkg == nil {
+		return fmt.Errorf("no such package: %d", id)
+	}
+	pkg.Name = &name
+	return nil
+}
+
+func (dw *MockDownloader) RenameLink(_ context.Context, id int64, name string) error {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	for i := range dw.dev.links {
+		if *dw.dev.links[i].Uuid == id {
+			dw.dev.links[i].Name = &name
+			return nil
+		}
+	}
+	return fmt.Errorf("no such link: %d", id)
+}
+
+// MovePackages moves packages after given package, or to the top of the list if there is no such package
+func (dw *MockDownloader) MovePackages(_ context.Context, packageIds []int64, afterPackageId int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	moved := slices.DeleteFunc(slices.Clone(dw.dev.packages), func(p DownloadPackage) bool {
+		return !matches(p.Uuid, packageIds)
+	})
+	dw.dev.packages = slices.DeleteFunc(dw.dev.packages, func(p DownloadPackage) bool {
+		return matches(p.Uuid, packageIds)
+	})
+	pos := slices.IndexFunc(dw.dev.packages, func(p DownloadPackage) bool {
+		return p.Uuid != nil && *p.Uuid == afterPackageId
+	})
+	dw.dev.packages = slices.Insert(dw.dev.packages, pos+1, moved...)
+	return nil
+}
+
+// MoveLinks moves links into destination package, after given link or to the top of the list if there is no such link
+func (dw *MockDownloader) MoveLinks(_ context.Context, linkIds []int64, afterLinkId int64, destPackageId int64) error {
+	if len(linkIds) == 0 {
+		return errors.New("linkIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	if dw.dev.downloadPackage(destPackageId) == nil {
+		return fmt.Errorf("no such package: %d", destPackageId)
+	}
+	dw.dev.moveLinks(linkIds, afterLinkId, destPackageId)
+	return nil
+}
+
+func (dw *MockDownloader) MoveToNewPackage(_ context.Context, linkIds []int64, packageIds []int64, name string, downloadPath string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	uuid := dw.dev.newId()
+	dw.dev.packages = append(dw.dev.packages, DownloadPackage{Uuid: &uuid, Name: &name, SaveTo: &downloadPath, Enabled: ptrTo(true)})
+	ids := make([]int64, 0)
+	for _, l := range dw.dev.links {
+		if matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds) {
+			ids = append(ids, *l.Uuid)
+		}
+	}
+	dw.dev.moveLinks(ids, 0, uuid)
+	return nil
+}
+
+func (dw *MockDownloader) SetEnabled(_ context.Context, enabled bool, linkIds []int64, packageIds []int64) error {
+	return dw.update(linkIds, packageIds, func(l *DownloadLink) {
+		l.Enabled = &enabled
+	}, func(p *DownloadPackage) {
+		p.Enabled = &enabled
+	})
+}
+
+func (dw *MockDownloader) SetPriority(_ context.Context, priority Priority, linkIds []int64, packageIds []int64) error {
+	value := string(priority)
+	return dw.update(linkIds, packageIds, func(l *DownloadLink) {
+		l.Priority = &value
+	}, func(p *DownloadPackage) {
+		p.Priority = &value
+	})
+}
+
+func (dw *MockDownloader) SetDownloadDirectory(_ context.Context, directory string, packageIds []int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	return dw.update(nil, packageIds, func(*DownloadLink) {}, func(p *DownloadPackage) {
+		p.SaveTo = &directory
+	})
+}
+
+func (dw *MockDownloader) SetDownloadPassword(_ context.Context, linkIds []int64, packageIds []int64, password string) error {
+	return dw.update(linkIds, packageIds, func(l *DownloadLink) {
+		l.DownloadPassword = &password
+	}, func(p *DownloadPackage) {
+		p.DownloadPassword = &password
+	})
+}
+
+// ResetLinks clears progress of given links
+func (dw *MockDownloader) ResetLinks(_ context.Context, linkIds []int64, packageIds []int64) error {
+	return dw.update(linkIds, packageIds, func(l *DownloadLink) {
+		l.BytesLoaded = new(int64)
+		l.Finished = new(bool)
+		l.Running = new(bool)
+		l.Skipped = new(bool)
+		l.ExtractionStatus = nil
+		l.Status = new(string)
+	}, func(*DownloadPackage) {})
+}
+
+// ResumeLinks un-skips given links
+func (dw *MockDownloader) ResumeLinks(_ context.Context, linkIds []int64, packageIds []int64) error {
+	return dw.update(linkIds, packageIds, func(l *DownloadLink) {
+		l.Skipped = new(bool)
+	}, func(*DownloadPackage) {})
+}
+
+func (dw *MockDownloader) SplitPackageByHoster(_ context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	byHost := make(map[string][]int64)
+	hosts := make([]string, 0)
+	for _, l := range dw.dev.links {
+		if matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds) {
+			var host string
+			if l.Host != nil {
+				host = *l.Host
+			}
+			if _, found := byHost[host]; !found {
+				hosts = append(hosts, host)
+			}
+			byHost[host] = append(byHost[host], *l.Uuid)
+		}
+	}
+	for _, host := range hosts {
+		uuid := dw.dev.newId()
+		name := host
+		dw.dev.packages = append(dw.dev.packages, DownloadPackage{Uuid: &uuid, Name: &name, Enabled: ptrTo(true)})
+		dw.dev.moveLinks(byHost[host], 0, uuid)
+	}
+	return nil
+}
+
+// DownloadUrls gets Url of given links, types are ignored
+func (dw *MockDownloader) DownloadUrls(_ context.Context, linkIds []int64, packageIds []int64, _ ...UrlDisplayType) (map[string][]int64, error) {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return nil, errors.New("one of linkIds or packageIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	urls := make(map[string][]int64)
+	for _, l := range dw.dev.links {
+		if l.Url != nil && (matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds)) {
+			urls[*l.Url] = append(urls[*l.Url], *l.Uuid)
+		}
+	}
+	return urls, nil
+}
+
+// Cleanup removes matching links. Link is offline if its status contains "offline".
+// Files are never touched, so mode is ignored.
+func (dw *MockDownloader) Cleanup(_ context.Context, linkIds []int64, packageIds []int64,
+	action CleanupAction, _ CleanupMode, selection CleanupSelection) error {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	urls := make(map[string]bool)
+	remove := make([]int64, 0)
+	for _, l := range dw.dev.links {
+		dupe := l.Url != nil && urls[*l.Url]
+		if l.Url != nil {
+			urls[*l.Url] = true
+		}
+		if !cleanupSelected(selection, matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds)) {
+			continue
+		}
+		var match bool
+		switch action {
+		case CleanupActionDeleteAll:
+			match = true
+		case CleanupActionDeleteDisabled:
+			match = l.Enabled != nil && !*l.Enabled
+		case CleanupActionDeleteFailed:
+			match = DefaultLinkFailed(l) != ""
+		case CleanupActionDeleteFinished, CleanupActionDeleteMode:
+			match = isTrue(l.Finished)
+		case CleanupActionDeleteOffline:
+			match = l.Status != nil && strings.Contains(strings.ToLower(*l.Status), "offline")
+		case CleanupActionDeleteDupe:
+			match = dupe
+		}
+		if match {
+			remove = append(remove, *l.Uuid)
+		}
+	}
+	dw.dev.removeDownloads(remove, nil)
+	return nil
+}
+
+func (dw *MockDownloader) SetStopMark(_ context.Context, linkId int64, packageId int64) error {
+	if (linkId == 0) == (packageId == 0) {
+		return errors.New("exactly one of linkId or packageId must be set")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	if linkId != 0 {
+		if !slices.ContainsFunc(dw.dev.links, func(l DownloadLink) bool { return *l.Uuid == linkId }) {
+			return fmt.Errorf("no such link: %d", linkId)
+		}
+		dw.dev.stopMark = linkId
+		return nil
+	}
+	if dw.dev.downloadPackage(packageId) == nil {
+		return fmt.Errorf("no such package: %d", packageId)
+	}
+	dw.dev.stopMark = packageId
+	return nil
 }
 
-func (dw *MockDownloader) Force([]int64, []int64) error {
+func (dw *MockDownloader) RemoveStopMark(context.Context) error {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	dw.dev.stopMark = 0
 	return nil
 }
 
-func (dw *MockDownloader) State() (*DownloadState, error) {
+func (dw *MockDownloader) StopMark(ctx context.Context) (*StopMark, error) {
+	dw.dev.lock.Lock()
+	uuid := dw.dev.stopMark
+	dw.dev.lock.Unlock()
+	if uuid == 0 {
+		return nil, nil
+	}
+	link, _ := dw.StopMarkedLink(ctx)
+	if link != nil {
+		return &StopMark{Uuid: uuid, Link: link}, nil
+	}
+	pkgs, _ := dw.Packages(ctx)
+	for i := range *pkgs {
+		if p := (*pkgs)[i]; *p.Uuid == uuid {
+			return &StopMark{Uuid: uuid, Package: &p}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (dw *MockDownloader) StopMarkedLink(context.Context) (*DownloadLink, error) {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	for _, l := range dw.dev.links {
+		if dw.dev.stopMark != 0 && *l.Uuid == dw.dev.stopMark {
+			return &l, nil
+		}
+	}
 	return nil, nil
 }
+
+// StructureChangeCounter changes whenever links or packages are added, removed or reordered
+func (dw *MockDownloader) StructureChangeCounter(_ context.Context, old int64) (int64, error) {
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	var fp strings.Builder
+	for _, p := range dw.dev.packages {
+		fmt.Fprintf(&fp, "p%d,", *p.Uuid)
+	}
+	for _, l := range dw.dev.links {
+		var pkg int64
+		if l.PackageUuid != nil {
+			pkg = *l.PackageUuid
+		}
+		fmt.Fprintf(&fp, "l%d:%d,", *l.Uuid, pkg)
+	}
+	return dw.dev.downloadStructure.changeCounter(fp.String(), old), nil
+}
+
+// update applies functions to matching links and packages
+func (dw *MockDownloader) update(linkIds []int64, packageIds []int64, linkFn func(*DownloadLink), pkgFn func(*DownloadPackage)) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	dw.dev.lock.Lock()
+	defer dw.dev.lock.Unlock()
+	for i := range dw.dev.links {
+		if matches(dw.dev.links[i].Uuid, linkIds) || matches(dw.dev.links[i].PackageUuid, packageIds) {
+			linkFn(&dw.dev.links[i])
+		}
+	}
+	for i := range dw.dev.packages {
+		if matches(dw.dev.packages[i].Uuid, packageIds) {
+			pkgFn(&dw.dev.packages[i])
+		}
+	}
+	return nil
+}
+
+// MockLinkGrabber is LinkGrabber backed by MockDevice. Links are "crawled" synchronously when added.
+type MockLinkGrabber struct {
+	dev *MockDevice
+}
+
+func (lg *MockLinkGrabber) Clear(context.Context) error {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	lg.dev.crawledLinks = nil
+	lg.dev.crawledPkgs = nil
+	return nil
+}
+
+func (lg *MockLinkGrabber) Packages(_ context.Context, options ...LinkGrabberQueryPackagesOptions) (*[]CrawledPackage, error) {
+	params := &QueryPackagesParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	res := make([]CrawledPackage, 0)
+	for _, p := range lg.dev.crawledPkgs {
+		if params.PackageUUIDs != nil && !matches(p.Uuid, *params.PackageUUIDs) {
+			continue
+		}
+		var childCount, online, offline, unknown int
+		var bytesTotal uint64
+		for _, l := range lg.dev.crawledLinks {
+			if *l.PackageUuid == *p.Uuid {
+				childCount++
+				switch {
+				case l.Availability == nil:
+					unknown++
+				case *l.Availability == availabilityOnline:
+					online++
+				case *l.Availability == "OFFLINE":
+					offline++
+				default:
+					unknown++
+				}
+				if l.BytesTotal != nil {
+					bytesTotal += *l.BytesTotal
+				}
+			}
+		}
+		p.ChildCount = &childCount
+		p.OnlineCount = &online
+		p.OfflineCount = &offline
+		p.UnknownCount = &unknown
+		p.BytesTotal = &bytesTotal
+		res = append(res, p)
+	}
+	res = deepCopy(mockPage(res, params.StartAt, params.MaxResults))
+	return &res, nil
+}
+
+func (lg *MockLinkGrabber) Links(_ context.Context, options ...LinkGrabberQueryLinksOptions) (*[]CrawledLink, error) {
+	params := &LinkGrabberQueryLinksParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	jobLinks := make([]int64, 0)
+	if params.JobUUIDs != nil {
+		for _, job := range *params.JobUUIDs {
+			jobLinks = append(jobLinks, lg.dev.crawlerJobs[job]...)
+		}
+	}
+	res := slices.DeleteFunc(slices.Clone(lg.dev.crawledLinks), func(l CrawledLink) bool {
+		return (params.PackageUUIDs != nil && !matches(l.PackageUuid, *params.PackageUUIDs)) ||
+			(params.JobUUIDs != nil && !matches(l.Uuid, jobLinks))
+	})
+	res = deepCopy(mockPage(res, params.StartAt, params.MaxResults))
+	return &res, nil
+}
+
+// Add creates crawled link for every URL, all of them in single package.
+// When autostart is requested, links are moved to download list immediately.
+func (lg *MockLinkGrabber) Add(_ context.Context, links []string, options ...AddLinksOptions) (*LinkCollectingJob, error) {
+	params := &AddLinksParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	pkgId := lg.dev.newId()
+	name := params.PackageName
+	if name == nil {
+		n := fmt.Sprintf("package-%d", pkgId)
+		name = &n
+	}
+	lg.dev.crawledPkgs = append(lg.dev.crawledPkgs, CrawledPackage{
+		Uuid:    &pkgId,
+		Name:    name,
+		SaveTo:  params.DestinationFolder,
+		Enabled: ptrTo(true),
+	})
+	linkIds := make([]int64, 0, len(links))
+	for _, link := range links {
+		id := lg.dev.newId()
+		l := CrawledLink{
+			Availability:     new(string),
+			BytesTotal:       new(uint64),
+			DownloadPassword: params.DownloadPassword,
+			Enabled:          ptrTo(true),
+			Name:             &link,
+			PackageUuid:      &pkgId,
+			Url:              &link,
+			Uuid:             &id,
+		}
+		*l.Availability = availabilityOnline
+		if u, err := url.Parse(link); err == nil {
+			host := u.Hostname()
+			l.Host = &host
+			if base := path.Base(u.Path); base != "." && base != "/" {
+				l.Name = &base
+			}
+		}
+		lg.dev.crawledLinks = append(lg.dev.crawledLinks, l)
+		linkIds = append(linkIds, id)
+	}
+	if params.Autostart != nil && *params.Autostart {
+		lg.dev.moveToDownloadList(nil, []int64{pkgId})
+	}
+	job := &LinkCollectingJob{Id: lg.dev.newId()}
+	if lg.dev.crawlerJobs == nil {
+		lg.dev.crawlerJobs = make(map[int64][]int64)
+	}
+	lg.dev.crawlerJobs[job.Id] = linkIds
+	return job, nil
+}
+
+// CrawlerJobs reports given jobs as finished, since the mock crawls links synchronously
+func (lg *MockLinkGrabber) CrawlerJobs(_ context.Context, jobIds ...int64) ([]CrawlerJob, error) {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	jobs := make([]CrawlerJob, 0)
+	for _, id := range jobIds {
+		if linkIds, found := lg.dev.crawlerJobs[id]; found {
+			crawled := len(linkIds)
+			jobs = append(jobs, CrawlerJob{JobId: &id, Checking: new(bool), Crawling: new(bool), Crawled: &crawled})
+		}
+	}
+	return jobs, nil
+}
+
+func (lg *MockLinkGrabber) AddAndWait(ctx context.Context, links []string, options ...AddLinksOptions) (*LinkCollectingResult, error) {
+	return addAndWait(ctx, lg, links, options...)
+}
+
+// AddContainer adds single link pointing to container, as the mock can't decrypt containers
+func (lg *MockLinkGrabber) AddContainer(ctx context.Context, containerType ContainerType, content []byte, options ...AddLinksOptions) (*LinkCollectingJob, error) {
+	if len(content) == 0 {
+		return nil, errors.New("container content must not be empty")
+	}
+	params := &AddLinksParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	if params.ExtractPassword != nil {
+		return nil, errors.New("extract password is not supported for containers")
+	}
+	job, err := lg.Add(ctx, []string{fmt.Sprintf("file:///container.%s", strings.ToLower(string(containerType)))},
+		AddLinksOptionAssignJobID(true))
+	if err != nil {
+		return nil, err
+	}
+	return job, applyAddLinksParams(ctx, lg, *job, params)
+}
+
+func (lg *MockLinkGrabber) IsCollecting(context.Context) (bool, error) {
+	return false, nil
+}
+
+func (lg *MockLinkGrabber) Remove(_ context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	lg.dev.removeCrawled(linkIds, packageIds)
+	return nil
+}
+
+func (lg *MockLinkGrabber) RenameLink(_ context.Context, id int64, name string) error {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	for i := range lg.dev.crawledLinks {
+		if *lg.dev.crawledLinks[i].Uuid == id {
+			lg.dev.crawledLinks[i].Name = &name
+			return nil
+		}
+	}
+	return fmt.Errorf("no such link: %d", id)
+}
+
+// Cleanup removes matching crawled links. Offline and failed actions both remove links with OFFLINE availability.
+func (lg *MockLinkGrabber) Cleanup(_ context.Context, linkIds []int64, packageIds []int64,
+	action CleanupAction, _ CleanupMode, selection CleanupSelection) error {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	urls := make(map[string]bool)
+	remove := make([]int64, 0)
+	for _, l := range lg.dev.crawledLinks {
+		dupe := l.Url != nil && urls[*l.Url]
+		if l.Url != nil {
+			urls[*l.Url] = true
+		}
+		if !cleanupSelected(selection, matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds)) {
+			continue
+		}
+		var match bool
+		switch action {
+		case CleanupActionDeleteAll:
+			match = true
+		case CleanupActionDeleteDisabled:
+			match = l.Enabled != nil && !*l.Enabled
+		case CleanupActionDeleteFailed, CleanupActionDeleteOffline:
+			match = l.Availability != nil && *l.Availability == "OFFLINE"
+		case CleanupActionDeleteDupe:
+			match = dupe
+		}
+		if match {
+			remove = append(remove, *l.Uuid)
+		}
+	}
+	lg.dev.removeCrawled(remove, nil)
+	return nil
+}
+
+// ChildrenChanged changes whenever links or packages are added, removed or reordered
+func (lg *MockLinkGrabber) ChildrenChanged(_ context.Context, old int64) (int64, error) {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	var fp strings.Builder
+	for _, p := range lg.dev.crawledPkgs {
+		fmt.Fprintf(&fp, "p%d,", *p.Uuid)
+	}
+	for _, l := range lg.dev.crawledLinks {
+		var pkg int64
+		if l.PackageUuid != nil {
+			pkg = *l.PackageUuid
+		}
+		fmt.Fprintf(&fp, "l%d:%d,", *l.Uuid, pkg)
+	}
+	return lg.dev.crawledStructure.changeCounter(fp.String(), old), nil
+}
+
+func (lg *MockLinkGrabber) RenamePackage(_ context.Context, id int64, name string) error {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	pkg := lg.dev.crawledPackage(id)
+	if pkg == nil {
+		return fmt.Errorf("no such package: %d", id)
+	}
+	pkg.Name = &name
+	return nil
+}
+
+func (lg *MockLinkGrabber) SetDownloadDirectory(_ context.Context, directory string, packageIds []int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	return lg.update(nil, packageIds, func(*CrawledLink) {}, func(p *CrawledPackage) {
+		p.SaveTo = &directory
+	})
+}
+
+func (lg *MockLinkGrabber) SetPriority(_ context.Context, priority Priority, linkIds []int64, packageIds []int64) error {
+	value := string(priority)
+	return lg.update(linkIds, packageIds, func(l *CrawledLink) {
+		l.Priority = &value
+	}, func(p *CrawledPackage) {
+		p.Priority = &value
+	})
+}
+
+func (lg *MockLinkGrabber) SetEnabled(_ context.Context, enabled bool, linkIds []int64, packageIds []int64) error {
+	return lg.update(linkIds, packageIds, func(l *CrawledLink) {
+		l.Enabled = &enabled
+	}, func(p *CrawledPackage) {
+		p.Enabled = &enabled
+	})
+}
+
+// MovePackages moves packages after given package, or to the top of the list if there is no such package
+func (lg *MockLinkGrabber) MovePackages(_ context.Context, packageIds []int64, afterPackageId int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	moved := slices.DeleteFunc(slices.Clone(lg.dev.crawledPkgs), func(p CrawledPackage) bool {
+		return !matches(p.Uuid, packageIds)
+	})
+	lg.dev.crawledPkgs = slices.DeleteFunc(lg.dev.crawledPkgs, func(p CrawledPackage) bool {
+		return matches(p.Uuid, packageIds)
+	})
+	pos := slices.IndexFunc(lg.dev.crawledPkgs, func(p CrawledPackage) bool {
+		return *p.Uuid == afterPackageId
+	})
+	lg.dev.crawledPkgs = slices.Insert(lg.dev.crawledPkgs, pos+1, moved...)
+	return nil
+}
+
+// MoveLinks moves links into destination package, after given link or to the top of the list if there is no such link
+func (lg *MockLinkGrabber) MoveLinks(_ context.Context, linkIds []int64, afterLinkId int64, destPackageId int64) error {
+	if len(linkIds) == 0 {
+		return errors.New("linkIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	if lg.dev.crawledPackage(destPackageId) == nil {
+		return fmt.Errorf("no such package: %d", destPackageId)
+	}
+	lg.dev.moveCrawledLinks(linkIds, afterLinkId, destPackageId)
+	return nil
+}
+
+func (lg *MockLinkGrabber) MoveToNewPackage(_ context.Context, linkIds []int64, packageIds []int64, name string, downloadPath string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	uuid := lg.dev.newId()
+	lg.dev.crawledPkgs = append(lg.dev.crawledPkgs, CrawledPackage{Uuid: &uuid, Name: &name, SaveTo: &downloadPath, Enabled: ptrTo(true)})
+	ids := make([]int64, 0)
+	for _, l := range lg.dev.crawledLinks {
+		if matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds) {
+			ids = append(ids, *l.Uuid)
+		}
+	}
+	lg.dev.moveCrawledLinks(ids, 0, uuid)
+	return nil
+}
+
+func (lg *MockLinkGrabber) SplitPackageByHoster(_ context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	byHost := make(map[string][]int64)
+	hosts := make([]string, 0)
+	for _, l := range lg.dev.crawledLinks {
+		if matches(l.Uuid, linkIds) || matches(l.PackageUuid, packageIds) {
+			var host string
+			if l.Host != nil {
+				host = *l.Host
+			}
+			if _, found := byHost[host]; !found {
+				hosts = append(hosts, host)
+			}
+			byHost[host] = append(byHost[host], *l.Uuid)
+		}
+	}
+	for _, host := range hosts {
+		uuid := lg.dev.newId()
+		name := host
+		lg.dev.crawledPkgs = append(lg.dev.crawledPkgs, CrawledPackage{Uuid: &uuid, Name: &name, Enabled: ptrTo(true)})
+		lg.dev.moveCrawledLinks(byHost[host], 0, uuid)
+	}
+	return nil
+}
+
+func (lg *MockLinkGrabber) SetDownloadPassword(_ context.Context, linkIds []int64, packageIds []int64, password string) error {
+	return lg.update(linkIds, packageIds, func(l *CrawledLink) {
+		l.DownloadPassword = &password
+	}, func(*CrawledPackage) {})
+}
+
+func (lg *MockLinkGrabber) SetComment(_ context.Context, linkIds []int64, packageIds []int64, setPackageChildren bool, comment string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	for i := range lg.dev.crawledLinks {
+		l := &lg.dev.crawledLinks[i]
+		if matches(l.Uuid, linkIds) || (setPackageChildren && matches(l.PackageUuid, packageIds)) {
+			l.Comment = &comment
+		}
+	}
+	for i := range lg.dev.crawledPkgs {
+		if matches(lg.dev.crawledPkgs[i].Uuid, packageIds) {
+			lg.dev.crawledPkgs[i].Comment = &comment
+		}
+	}
+	return nil
+}
+
+// StartOnlineStatusCheck marks links with unknown availability as online
+func (lg *MockLinkGrabber) StartOnlineStatusCheck(_ context.Context, linkIds []int64, packageIds []int64) error {
+	return lg.update(linkIds, packageIds, func(l *CrawledLink) {
+		if l.Availability == nil || (*l.Availability != availabilityOnline && *l.Availability != "OFFLINE") {
+			online := availabilityOnline
+			l.Availability = &online
+		}
+	}, func(*CrawledPackage) {})
+}
+
+// update applies functions to matching crawled links and packages
+func (lg *MockLinkGrabber) update(linkIds []int64, packageIds []int64, linkFn func(*CrawledLink), pkgFn func(*CrawledPackage)) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	for i := range lg.dev.crawledLinks {
+		if matches(lg.dev.crawledLinks[i].Uuid, linkIds) || matches(lg.dev.crawledLinks[i].PackageUuid, packageIds) {
+			linkFn(&lg.dev.crawledLinks[i])
+		}
+	}
+	for i := range lg.dev.crawledPkgs {
+		if matches(lg.dev.crawledPkgs[i].Uuid, packageIds) {
+			pkgFn(&lg.dev.crawledPkgs[i])
+		}
+	}
+	return nil
+}
+
+func (lg *MockLinkGrabber) Variants(_ context.Context, linkId int64) ([]LinkVariant, error) {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	return deepCopy(lg.dev.variants[linkId]), nil
+}
+
+func (lg *MockLinkGrabber) SetVariant(_ context.Context, linkId int64, variantId string) error {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	variant, err := lg.dev.variant(linkId, variantId)
+	if err != nil {
+		return err
+	}
+	for i := range lg.dev.crawledLinks {
+		if *lg.dev.crawledLinks[i].Uuid == linkId {
+			lg.dev.crawledLinks[i].Variant = variant
+		}
+	}
+	return nil
+}
+
+// AddVariantCopy inserts copy of link after given link, or to the top of the list if there is no such link
+func (lg *MockLinkGrabber) AddVariantCopy(_ context.Context, linkId int64, afterLinkId int64, destPackageId int64, variantId string) error {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	variant, err := lg.dev.variant(linkId, variantId)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(lg.dev.crawledPkgs, func(p CrawledPackage) bool { return *p.Uuid == destPackageId }) {
+		return fmt.Errorf("no such package: %d", destPackageId)
+	}
+	i := slices.IndexFunc(lg.dev.crawledLinks, func(l CrawledLink) bool { return *l.Uuid == linkId })
+	link := lg.dev.crawledLinks[i]
+	uuid := lg.dev.newId()
+	link.Uuid, link.PackageUuid, link.Variant = &uuid, &destPackageId, variant
+	lg.dev.variants[uuid] = lg.dev.variants[linkId]
+	pos := slices.IndexFunc(lg.dev.crawledLinks, func(l CrawledLink) bool { return *l.Uuid == afterLinkId })
+	lg.dev.crawledLinks = slices.Insert(lg.dev.crawledLinks, pos+1, link)
+	return nil
+}
+
+// ConfirmAllOnline moves crawled links with ONLINE availability into download list
+func (lg *MockLinkGrabber) ConfirmAllOnline(context.Context) ([]int64, error) {
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	online := make([]int64, 0)
+	for _, l := range lg.dev.crawledLinks {
+		if l.Availability != nil && *l.Availability == availabilityOnline {
+			online = append(online, *l.Uuid)
+		}
+	}
+	if len(online) > 0 {
+		lg.dev.moveToDownloadList(online, nil)
+	}
+	return online, nil
+}
+
+// MoveToDownloadList moves given crawled links and/or packages into download list
+func (lg *MockLinkGrabber) MoveToDownloadList(_ context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	lg.dev.lock.Lock()
+	defer lg.dev.lock.Unlock()
+	lg.dev.moveToDownloadList(linkIds, packageIds)
+	return nil
+}
+
+// MockEvents is Events backed by MockDevice. Events are produced by MockDevice.Emit.
+type MockEvents struct {
+	dev *MockDevice
+}
+
+func (me *MockEvents) Publishers(context.Context) (*[]EventPublisherInfo, error) {
+	res := make([]EventPublisherInfo, 0)
+	for _, t := range []EventType{EventTypeDownloadController, EventTypeDownloads, EventTypeLinkGrabber, EventTypeCaptcha} {
+		res = append(res, EventPublisherInfo{Publisher: string(t), EventIds: []string{}})
+	}
+	return &res, nil
+}
+
+func (me *MockEvents) Subscribe(ctx context.Context, options ...EventSubscribeOptions) (<-chan Event, error) {
+	params := &EventSubscribeParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	sub := &mockSubscriber{
+		ctx:    ctx,
+		ch:     make(chan Event, params.BufferSize),
+		params: params,
+	}
+	out := make(chan Event)
+	sub.out = out
+	me.dev.lock.Lock()
+	me.dev.subscribers = append(me.dev.subscribers, sub)
+	me.dev.lock.Unlock()
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-sub.ch:
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// ChangeSubscription replaces filters of subscription, so that they apply to events emitted afterwards
+func (me *MockEvents) ChangeSubscription(_ context.Context, ch <-chan Event, options ...EventSubscribeOptions) error {
+	me.dev.lock.Lock()
+	defer me.dev.lock.Unlock()
+	i := slices.IndexFunc(me.dev.subscribers, func(s *mockSubscriber) bool { return s.out == ch })
+	if i < 0 {
+		return errors.New("no such subscription")
+	}
+	params := *me.dev.subscribers[i].params
+	params.Subscriptions, params.Exclusions = nil, nil
+	for _, opt := range options {
+		opt(&params)
+	}
+	me.dev.subscribers[i].params = &params
+	return nil
+}
+
+var (
+	_ JdClient    = &MockClient{}
+	_ Device      = &MockDevice{}
+	_ Downloader  = &MockDownloader{}
+	_ LinkGrabber = &MockLinkGrabber{}
+	_ Events      = &MockEvents{}
+)
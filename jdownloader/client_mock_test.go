@@ -0,0 +1,288 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockWorkflow(t *testing.T) {
+	ctx := t.Context()
+	c := jdownloader.NewMockClient()
+	c.SetDevices(&[]jdownloader.DeviceInfo{{Id: "1", Name: "dev1", Status: "ONLINE"}})
+	dev, err := c.Device(ctx, "dev1")
+	assert.NoError(t, err)
+	assert.Equal(t, "dev1", dev.Name())
+	_, err = c.Device(ctx, "dev2")
+	assert.Error(t, err)
+
+	lg := dev.LinkGrabber()
+	_, err = lg.Add(ctx, []string{"https://host1.tld/a.zip", "https://host2.tld/b.zip"},
+		jdownloader.AddLinksOptionPackage("pkg1"))
+	assert.NoError(t, err)
+	crawled, err := lg.Links(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, *crawled, 2)
+	assert.Equal(t, "a.zip", *(*crawled)[0].Name)
+	pkgs, err := lg.Packages(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, *pkgs, 1)
+	assert.Equal(t, 2, *(*pkgs)[0].ChildCount)
+
+	// confirm package
+	assert.NoError(t, lg.MoveToDownloadList(ctx, nil, []int64{*(*pkgs)[0].Uuid}))
+	crawled, err = lg.Links(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, *crawled)
+
+	// device state is shared across instances
+	dl := dev.Downloader()
+	dev, _ = c.Device(ctx, "dev1")
+	links, err := dev.Downloader().Links(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, *links, 2)
+
+	// state machine
+	state, _ := dl.State(ctx)
+	assert.Equal(t, "IDLE", *state.State)
+	started, _ := dl.Start(ctx)
+	assert.True(t, started)
+	started, _ = dl.Start(ctx)
+	assert.False(t, started)
+	dpkgs, err := dl.Packages(ctx)
+	assert.NoError(t, err)
+	assert.True(t, *(*dpkgs)[0].Running)
+	paused, _ := dl.Pause(ctx)
+	assert.True(t, paused)
+	state, _ = dl.State(ctx)
+	assert.Equal(t, "PAUSE", *state.State)
+	stopped, _ := dl.Stop(ctx)
+	assert.True(t, stopped)
+	state, _ = dl.State(ctx)
+	assert.Equal(t, "STOPPED", *state.State)
+
+	// force single link
+	linkId := *(*links)[0].Uuid
+	assert.NoError(t, dl.Force(ctx, []int64{linkId}, nil))
+	links, _ = dl.Links(ctx)
+	assert.True(t, *(*links)[0].Running)
+	assert.False(t, *(*links)[1].Running)
+
+	// remove
+	assert.NoError(t, dl.Remove(ctx, []int64{linkId}, nil))
+	links, _ = dl.Links(ctx)
+	assert.Len(t, *links, 1)
+	assert.NoError(t, dl.Remove(ctx, []int64{*(*links)[0].Uuid}, nil))
+	dpkgs, _ = dl.Packages(ctx)
+	assert.Empty(t, *dpkgs)
+}
+
+func TestMockAutostart(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	_, err := dev.LinkGrabber().Add(ctx, []string{"https://host1.tld/a.zip"}, jdownloader.AddLinksOptionAutostart(true))
+	assert.NoError(t, err)
+	links, err := dev.Downloader().Links(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, *links, 1)
+	dev.SetSpeed(100)
+	speed, _ := dev.Downloader().Speed(ctx)
+	assert.Equal(t, float64(0), *speed.Speed)
+	_, _ = dev.Downloader().Start(ctx)
+	speed, _ = dev.Downloader().Speed(ctx)
+	assert.Equal(t, float64(100), *speed.Speed)
+}
+
+func TestMockPackageUUIDFilter(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	lg := dev.LinkGrabber()
+	_, err := lg.Add(ctx, []string{"https://a.tld/1"}, jdownloader.AddLinksOptionPackage("p1"))
+	assert.NoError(t, err)
+	_, err = lg.Add(ctx, []string{"https://a.tld/2", "https://a.tld/3"}, jdownloader.AddLinksOptionPackage("p2"))
+	assert.NoError(t, err)
+	pkgs, _ := lg.Packages(ctx)
+	p2 := *(*pkgs)[1].Uuid
+	filtered, _ := lg.Packages(ctx, jdownloader.LinkGrabberQueryPackagesOptionPackageUUIDs(p2))
+	assert.Len(t, *filtered, 1)
+	links, _ := lg.Links(ctx, jdownloader.LinkGrabberQueryLinksOptionPackageUUIDs(p2))
+	assert.Len(t, *links, 2)
+
+	assert.NoError(t, lg.MoveToDownloadList(ctx, nil, []int64{p2}))
+	dpkgs, _ := dev.Downloader().Packages(ctx, jdownloader.DownloadQueryPackagesOptionPackageUUIDs(p2))
+	assert.Len(t, *dpkgs, 1)
+	assert.Equal(t, 2, *(*dpkgs)[0].ChildCount)
+}
+
+func TestMockConfirmAllOnline(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	lg := dev.LinkGrabber()
+	_, err := lg.Add(ctx, []string{"https://a.tld/1", "https://a.tld/2"})
+	assert.NoError(t, err)
+	crawled, _ := lg.Links(ctx)
+	offline := *(*crawled)[1].Uuid
+	assert.NoError(t, dev.UpdateCrawledLink(offline, func(l *jdownloader.CrawledLink) {
+		l.Availability = ptr("OFFLINE")
+	}))
+	confirmed, err := lg.ConfirmAllOnline(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{*(*crawled)[0].Uuid}, confirmed)
+	crawled, _ = lg.Links(ctx)
+	assert.Len(t, *crawled, 1)
+	assert.Equal(t, offline, *(*crawled)[0].Uuid)
+}
+
+func TestMockVariants(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	lg := dev.LinkGrabber()
+	_, err := lg.Add(ctx, []string{"https://video.tld/watch"})
+	assert.NoError(t, err)
+	crawled, _ := lg.Links(ctx)
+	link := (*crawled)[0]
+	assert.NoError(t, dev.SetVariants(*link.Uuid, []jdownloader.LinkVariant{
+		{Id: ptr("360"), Name: ptr("360p")}, {Id: ptr("1080"), Name: ptr("1080p")},
+	}))
+	assert.NoError(t, lg.SetVariant(ctx, *link.Uuid, "1080"))
+	assert.Error(t, lg.SetVariant(ctx, *link.Uuid, "4k"))
+	assert.NoError(t, lg.AddVariantCopy(ctx, *link.Uuid, *link.Uuid, *link.PackageUuid, "360"))
+	crawled, _ = lg.Links(ctx)
+	assert.Len(t, *crawled, 2)
+	assert.Equal(t, "1080", *(*crawled)[0].Variant.Id)
+	assert.Equal(t, "360", *(*crawled)[1].Variant.Id)
+	variants, _ := lg.Variants(ctx, *(*crawled)[1].Uuid)
+	assert.Len(t, variants, 2)
+}
+
+func TestMockLinkGrabberPackageManagement(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	lg := dev.LinkGrabber()
+	_, err := lg.Add(ctx, []string{"https://a.tld/1", "https://b.tld/2", "https://a.tld/3"}, jdownloader.AddLinksOptionPackage("mixed"))
+	assert.NoError(t, err)
+	pkgs, _ := lg.Packages(ctx)
+	pkg := *(*pkgs)[0].Uuid
+
+	assert.NoError(t, lg.RenamePackage(ctx, pkg, "renamed"))
+	assert.NoError(t, lg.SetComment(ctx, nil, []int64{pkg}, true, "reviewed"))
+	assert.NoError(t, lg.SetPriority(ctx, jdownloader.PriorityHighest, nil, []int64{pkg}))
+	assert.NoError(t, lg.SplitPackageByHoster(ctx, nil, []int64{pkg}))
+	pkgs, _ = lg.Packages(ctx)
+	assert.Len(t, *pkgs, 2)
+	assert.Equal(t, "a.tld", *(*pkgs)[0].Name)
+	assert.Equal(t, 2, *(*pkgs)[0].ChildCount)
+	links, _ := lg.Links(ctx)
+	for _, l := range *links {
+		assert.Equal(t, "reviewed", *l.Comment)
+		assert.Equal(t, "HIGHEST", *l.Priority)
+	}
+
+	assert.NoError(t, lg.MovePackages(ctx, []int64{*(*pkgs)[0].Uuid}, *(*pkgs)[1].Uuid))
+	pkgs, _ = lg.Packages(ctx)
+	assert.Equal(t, "b.tld", *(*pkgs)[0].Name)
+
+	// moving link out of two-link package keeps both packages
+	assert.Equal(t, "a.tld", *(*links)[1].Host)
+	assert.NoError(t, lg.MoveToNewPackage(ctx, []int64{*(*links)[1].Uuid}, nil, "single", "/mnt"))
+	pkgs, _ = lg.Packages(ctx)
+	assert.Len(t, *pkgs, 3)
+	assert.Equal(t, "/mnt", *(*pkgs)[2].SaveTo)
+}
+
+func TestMockAddAndWait(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	lg := dev.LinkGrabber()
+	first, err := lg.Add(ctx, []string{"https://a.tld/1"})
+	assert.NoError(t, err)
+	res, err := lg.AddAndWait(ctx, []string{"https://b.tld/1", "https://b.tld/2"}, jdownloader.AddLinksOptionPackage("b"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Id, res.Job.Id)
+	assert.Len(t, res.Links, 2)
+	assert.Len(t, res.Packages, 1)
+	assert.Equal(t, "b", *res.Packages[0].Name)
+	jobs, _ := lg.CrawlerJobs(ctx, first.Id)
+	assert.Equal(t, 1, *jobs[0].Crawled)
+}
+
+func TestMockAddContainerOptions(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	_, err := dev.LinkGrabber().AddContainer(ctx, jdownloader.ContainerTypeDLC, []byte("dlc-content"),
+		jdownloader.AddLinksOptionPackage("container"), jdownloader.AddLinksOptionAutostart(true))
+	assert.NoError(t, err)
+	crawled, _ := dev.LinkGrabber().Packages(ctx)
+	assert.Empty(t, *crawled)
+	pkgs, _ := dev.Downloader().Packages(ctx)
+	assert.Len(t, *pkgs, 1)
+	assert.Equal(t, "container", *(*pkgs)[0].Name)
+}
+
+func TestMockLinksWithoutUuid(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	name := "a.zip"
+	dev.SetPackages(&[]jdownloader.DownloadPackage{{Name: &name}})
+	dev.SetLinks(&[]jdownloader.DownloadLink{{Name: &name}, {Name: &name}})
+	view := jdownloader.NewDownloadView(dev.Downloader())
+	changed, err := view.Refresh(ctx)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	links := view.Links()
+	assert.Len(t, links, 2)
+	assert.NotNil(t, links[0].Uuid)
+	assert.NotEqual(t, *links[0].Uuid, *links[1].Uuid)
+	assert.NotNil(t, view.Packages()[0].Uuid)
+	assert.NoError(t, dev.Downloader().RenameLink(ctx, *links[0].Uuid, "b.zip"))
+}
+
+func TestMockSessionState(t *testing.T) {
+	c := jdownloader.NewMockClient()
+	_, err := c.SessionState()
+	assert.Error(t, err)
+	_, err = c.ExportSessionState(false)
+	assert.Error(t, err)
+	assert.NoError(t, c.Connect(t.Context()))
+	_, err = c.SessionState()
+	assert.NoError(t, err)
+}
+
+func TestMockReturnsCopies(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	_, err := dev.LinkGrabber().Add(ctx, []string{"https://a.tld/1"}, jdownloader.AddLinksOptionAutostart(true))
+	assert.NoError(t, err)
+
+	links, _ := dev.Downloader().Links(ctx)
+	*(*links)[0].Enabled = false
+	*(*links)[0].Name = "changed"
+	pkgs, _ := dev.Downloader().Packages(ctx)
+	*(*pkgs)[0].Enabled = false
+
+	params := &jdownloader.DownloadQueryLinksParams{}
+	jdownloader.DefaultDownloadQueryLinksOptions()(params)
+	assert.True(t, *params.Enabled)
+	links, _ = dev.Downloader().Links(ctx)
+	assert.True(t, *(*links)[0].Enabled)
+	assert.Equal(t, "1", *(*links)[0].Name)
+	pkgs, _ = dev.Downloader().Packages(ctx)
+	assert.True(t, *(*pkgs)[0].Enabled)
+}
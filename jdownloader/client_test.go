@@ -0,0 +1,225 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testEmail    = "test@acme.tld"
+	testPassword = "123456"
+)
+
+func newTestServer(t *testing.T) *jdtest.Server {
+	s := jdtest.NewServer(testEmail, testPassword)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestClient(s *jdtest.Server, opts ...jdownloader.ClientOption) jdownloader.JdClient {
+	opts = append([]jdownloader.ClientOption{jdownloader.ClientOptionApiEndpoint(s.URL())}, opts...)
+	return jdownloader.NewClient(testEmail, testPassword, slog.Default(), opts...)
+}
+
+// newTestDevice starts fake server with single device "dev1" and gets that device through new client
+func newTestDevice(t *testing.T, opts ...jdownloader.ClientOption) (*jdtest.Server, *jdtest.Device, jdownloader.Device) {
+	s := newTestServer(t)
+	d := s.AddDevice("dev1", "dev1")
+	dev, err := newTestClient(s, opts...).Device(t.Context(), "dev1")
+	if err != nil {
+		t.Fatalf("unable to get test device: %v", err)
+	}
+	return s, d, dev
+}
+
+// newMockDevice creates mock client with single device "dev1" and returns that device
+func newMockDevice(t *testing.T) *jdownloader.MockDevice {
+	c := jdownloader.NewMockClient()
+	c.SetDevices(&[]jdownloader.DeviceInfo{{Name: "dev1"}})
+	return c.MockDevice("dev1")
+}
+
+func TestConnectHonoursContextDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := jdownloader.NewClient(testEmail, testPassword, slog.Default(), jdownloader.ClientOptionApiEndpoint(srv.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.Connect(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.False(t, c.IsConnected())
+}
+
+func TestInvalidCredentials(t *testing.T) {
+	s := newTestServer(t)
+	c := jdownloader.NewClient(testEmail, "wrong", slog.Default(), jdownloader.ClientOptionApiEndpoint(s.URL()))
+	err := c.Connect(t.Context())
+	assert.ErrorIs(t, err, jdownloader.ErrAuthFailed)
+}
+
+func TestSessionIsRegainedOnTokenInvalid(t *testing.T) {
+	s := newTestServer(t)
+	s.AddDevice("dev1", "dev1").Respond("/downloadcontroller/getCurrentState", "RUNNING")
+	c := newTestClient(s)
+	dev, err := c.Device(t.Context(), "dev1")
+	assert.NoError(t, err)
+
+	s.ExpireSession()
+	state, err := dev.Downloader().State(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "RUNNING", *state.State)
+	assert.Equal(t, 1, s.Connects())
+	assert.Equal(t, 1, s.Reconnects())
+
+	// regain token is rejected, client must fall back to full login
+	s.ExpireSession()
+	s.InvalidateRegainToken()
+	_, err = c.ListDevices(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, s.Connects())
+	assert.Equal(t, 1, s.Reconnects())
+}
+
+func TestConcurrentDeviceCalls(t *testing.T) {
+	s := newTestServer(t)
+	var inFlight, maxInFlight atomic.Int32
+	slow := func(result interface{}) jdtest.ActionHandler {
+		return func([]json.RawMessage) (interface{}, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return result, nil
+		}
+	}
+	for _, name := range []string{"dev1", "dev2"} {
+		d := s.AddDevice(name, name)
+		d.Handle("/downloadsV2/queryLinks", slow([]jdownloader.DownloadLink{{}}))
+		d.Handle("/downloadsV2/queryPackages", slow([]jdownloader.DownloadPackage{{}, {}}))
+	}
+
+	c := newTestClient(s)
+	devs := make([]jdownloader.Device, 0)
+	for _, name := range []string{"dev1", "dev2"} {
+		dev, err := c.Device(t.Context(), name)
+		assert.NoError(t, err)
+		devs = append(devs, dev)
+	}
+	s.ExpireSession()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, dev := range devs {
+			wg.Go(func() {
+				links, err := dev.Downloader().Links(t.Context())
+				assert.NoError(t, err)
+				assert.Len(t, *links, 1)
+			})
+			wg.Go(func() {
+				pkgs, err := dev.Downloader().Packages(t.Context())
+				assert.NoError(t, err)
+				assert.Len(t, *pkgs, 2)
+			})
+		}
+	}
+	wg.Wait()
+	assert.Greater(t, maxInFlight.Load(), int32(1))
+	assert.Equal(t, 1, s.Connects())
+	assert.Equal(t, 1, s.Reconnects())
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var calls, failures atomic.Int32
+	policy := jdownloader.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.OverloadBackoff = 2 * time.Millisecond
+	s, d, dev := newTestDevice(t,
+		jdownloader.ClientOptionRetryPolicy(policy),
+		jdownloader.ClientOptionApiCallbacks(func(err error, _ time.Duration) {
+			calls.Add(1)
+			if err != nil {
+				failures.Add(1)
+			}
+		}))
+	d.Respond("/downloadcontroller/getSpeedInBps", 10)
+	d.Respond("/downloadcontroller/start", true)
+	calls.Store(0)
+
+	// idempotent query is retried
+	s.Inject("/downloadcontroller/getSpeedInBps", 1, http.StatusServiceUnavailable, "")
+	s.Inject("/downloadcontroller/getSpeedInBps", 1, http.StatusServiceUnavailable, jdownloader.ErrorTypeOverload)
+	speed, err := dev.Downloader().Speed(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), *speed.Speed)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, int32(2), failures.Load())
+
+	// gives up after MaxAttempts
+	s.Inject("/downloadcontroller/getSpeedInBps", 4, http.StatusTooManyRequests, jdownloader.ErrorTypeTooManyRequests)
+	_, err = dev.Downloader().Speed(t.Context())
+	assert.ErrorIs(t, err, jdownloader.ErrTooManyRequests)
+	assert.Equal(t, 7, s.Calls("/downloadcontroller/getSpeedInBps"))
+
+	// mutating action is not retried
+	s.Inject("/downloadcontroller/start", 1, http.StatusServiceUnavailable, jdownloader.ErrorTypeOverload)
+	_, err = dev.Downloader().Start(t.Context())
+	assert.ErrorIs(t, err, jdownloader.ErrOverload)
+	assert.Equal(t, 1, s.Calls("/downloadcontroller/start"))
+
+	// ... unless explicitly allowed
+	policy.RetryMutating = true
+	s.Inject("/downloadcontroller/start", 1, http.StatusServiceUnavailable, jdownloader.ErrorTypeOverload)
+	started, err := dev.Downloader().Start(t.Context())
+	assert.NoError(t, err)
+	assert.True(t, started)
+	assert.Equal(t, 3, s.Calls("/downloadcontroller/start"))
+}
+
+func TestDeviceErrors(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+
+	_, err := dev.Downloader().State(t.Context())
+	assert.ErrorIs(t, err, jdownloader.ErrDeviceException)
+	assert.ErrorIs(t, err, &jdownloader.APIError{Type: jdownloader.ErrorTypeApiCommandNotFound})
+
+	d.SetStatus("OFFLINE")
+	_, err = dev.Downloader().State(t.Context())
+	assert.ErrorIs(t, err, jdownloader.ErrOffline)
+}
@@ -23,6 +23,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -40,9 +41,15 @@ func decrypt(ciphertext []byte, key [32]byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("ciphertext length %d is not a multiple of block size", len(ciphertext))
+	}
 	decrypter := cipher.NewCBCDecrypter(block, key[:16])
 	plaintext := make([]byte, len(ciphertext))
 	decrypter.CryptBlocks(plaintext, ciphertext)
+	if padding := int(plaintext[len(plaintext)-1]); padding == 0 || padding > len(plaintext) {
+		return nil, errors.New("invalid padding")
+	}
 	plaintext = trimPKCS5(plaintext)
 	return plaintext, nil
 }
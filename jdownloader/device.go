@@ -17,6 +17,7 @@ limitations under the License.
 package jdownloader
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -47,8 +48,12 @@ type Device interface {
 	Id() string
 	// Status get this device's status
 	Status() string
+	// Events gets reference to Events interface
+	Events() Events
 	// ConnectionInfo gets direct connection info
-	ConnectionInfo() (*DirectConnectionInfo, error)
+	ConnectionInfo(context.Context) (*DirectConnectionInfo, error)
+	// Poll gets aggregated state of device in single request
+	Poll(context.Context, ...PollOptions) (*PollSnapshot, error)
 }
 
 type jDevice struct {
@@ -57,6 +62,7 @@ type jDevice struct {
 	status string
 	log    *slog.Logger
 	impl   *jDownloaderClient
+	direct *directConnection
 }
 
 func (d *jDevice) LinkGrabber() LinkGrabber {
@@ -67,6 +73,10 @@ func (d *jDevice) Downloader() Downloader {
 	return newDownloadController(d.log, d)
 }
 
+func (d *jDevice) Events() Events {
+	return newDeviceEvents(d.log, d)
+}
+
 func (d *jDevice) Name() string {
 	return d.name
 }
@@ -79,8 +89,8 @@ func (d *jDevice) Id() string {
 	return d.id
 }
 
-func (d *jDevice) ConnectionInfo() (*DirectConnectionInfo, error) {
-	data, err := d.doDevice("/device/getDirectConnectionInfos", false)
+func (d *jDevice) ConnectionInfo(ctx context.Context) (*DirectConnectionInfo, error) {
+	data, err := d.doDevice(ctx, "/device/getDirectConnectionInfos", false)
 	if err != nil {
 		return nil, err
 	}
@@ -111,24 +121,47 @@ func serializeParams(marshal bool, params ...interface{}) ([]interface{}, error)
 	return ps, nil
 }
 
-func (d *jDevice) doDevice(action string, marshal bool, params ...interface{}) (_ *DataResponse, err error) {
+func (d *jDevice) doDevice(ctx context.Context, action string, marshal bool, params ...interface{}) (_ *DataResponse, err error) {
 	// Ensure impl is not nil
 	if d.impl == nil {
 		return nil, fmt.Errorf("device implementation is not initialized")
 	}
 
-	err = d.impl.reconnectIfNecessary()
+	p, err := serializeParams(marshal, params...)
 	if err != nil {
 		return nil, err
 	}
-	qs := fmt.Sprintf("t_%s_%s%s", url.QueryEscape(d.impl.sessionToken), url.QueryEscape(d.id), action)
-	p, err := serializeParams(marshal, params...)
+	body, err := d.impl.withRetry(ctx, action, func() ([]byte, error) {
+		return d.impl.withSession(ctx, func(s sessionTokens) ([]byte, error) {
+			if endpoint := d.directEndpoint(ctx, s); endpoint != "" {
+				data, err := d.call(ctx, s, endpoint, action, p)
+				if !isTransportError(ctx, err) {
+					return data, err
+				}
+				d.log.Warn("direct connection failed, falling back to relay", "endpoint", endpoint, "error", err)
+				d.invalidateDirectEndpoint()
+			}
+			return d.call(ctx, s, "", action, p)
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
+	result := &DataResponse{}
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// call sends single encrypted action request to device using given session.
+// When endpoint is empty, request is relayed via API server.
+func (d *jDevice) call(ctx context.Context, s sessionTokens, endpoint string, action string, params []interface{}) ([]byte, error) {
+	qs := fmt.Sprintf("t_%s_%s%s", url.QueryEscape(s.sessionToken), url.QueryEscape(d.id), action)
 	data := &actionRequest{
 		Url:        action,
-		Params:     p,
+		Params:     params,
 		RequestId:  d.impl.nextRid(),
 		ApiVersion: 1,
 	}
@@ -136,21 +169,21 @@ func (d *jDevice) doDevice(action string, marshal bool, params ...interface{}) (
 	if err != nil {
 		return nil, err
 	}
-	ciphertext, err := encrypt(plaintext, d.impl.deviceEncryptionToken)
+	ciphertext, err := encrypt(plaintext, s.deviceEncryptionToken)
 	if err != nil {
 		return nil, err
 	}
 	payload := base64.StdEncoding.EncodeToString(ciphertext)
-	body, err := d.impl.do(fmt.Sprintf("/%s", qs), http.MethodPost, []byte(payload), d.impl.deviceEncryptionToken)
-	if err != nil {
-		return nil, err
-	}
-	result := &DataResponse{}
-	err = json.Unmarshal(body, result)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
+	return d.impl.do(ctx, &apiCall{
+		source:   ErrorSourceDevice,
+		endpoint: endpoint,
+		action:   action,
+		path:     fmt.Sprintf("/%s", qs),
+		method:   http.MethodPost,
+		data:     []byte(payload),
+		key:      s.deviceEncryptionToken,
+		rid:      data.RequestId,
+	})
 }
 
 var _ Device = &jDevice{}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// directProbeTimeout is maximal time allowed for device to answer ping over direct connection
+	directProbeTimeout = 2 * time.Second
+	// directProbeInterval is minimal time between two attempts to discover direct connection
+	directProbeInterval = 5 * time.Minute
+	// directDiscoveryTimeout bounds whole discovery, including relay call and all probes
+	directDiscoveryTimeout = 15 * time.Second
+)
+
+// directConnection holds state of direct connection to single device
+type directConnection struct {
+	lock     sync.Mutex
+	endpoint string
+	checked  time.Time
+	// discovery is closed once running discovery finishes, nil when there is none
+	discovery chan struct{}
+}
+
+// directEndpoint returns base URL of device's direct connection, or empty string if device must be reached via relay.
+// Discovery is performed lazily and repeated at most once per directProbeInterval.
+// Only caller which started discovery waits for it, others use relay meanwhile.
+func (d *jDevice) directEndpoint(ctx context.Context, s sessionTokens) string {
+	if d.direct == nil {
+		return ""
+	}
+	d.direct.lock.Lock()
+	if d.direct.endpoint != "" || d.direct.discovery != nil || time.Since(d.direct.checked) < directProbeInterval {
+		defer d.direct.lock.Unlock()
+		return d.direct.endpoint
+	}
+	done := make(chan struct{})
+	d.direct.discovery = done
+	d.direct.lock.Unlock()
+	go func() {
+		defer close(done)
+		// detached from caller, so that short deadline of single request doesn't disable direct connection
+		dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), directDiscoveryTimeout)
+		defer cancel()
+		endpoint := d.discoverDirectEndpoint(dctx, s)
+		d.direct.lock.Lock()
+		defer d.direct.lock.Unlock()
+		d.direct.endpoint = endpoint
+		d.direct.checked = time.Now()
+		d.direct.discovery = nil
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ""
+	}
+	d.direct.lock.Lock()
+	defer d.direct.lock.Unlock()
+	return d.direct.endpoint
+}
+
+// invalidateDirectEndpoint forgets current direct connection, so that subsequent calls use relay
+func (d *jDevice) invalidateDirectEndpoint() {
+	d.direct.lock.Lock()
+	defer d.direct.lock.Unlock()
+	d.direct.endpoint = ""
+}
+
+func (d *jDevice) discoverDirectEndpoint(ctx context.Context, s sessionTokens) string {
+	body, err := d.call(ctx, s, "", "/device/getDirectConnectionInfos", nil)
+	if err != nil {
+		d.log.Warn("unable to get direct connection info", "error", err)
+		return ""
+	}
+	resp := &DataResponse{}
+	info := &DirectConnectionInfo{}
+	if err = json.Unmarshal(body, resp); err == nil {
+		err = toObj(resp, info)
+	}
+	if err != nil {
+		d.log.Warn("invalid direct connection info received", "error", err)
+		return ""
+	}
+	if info.RebindProtectionDetected != nil && *info.RebindProtectionDetected {
+		d.log.Info("rebind protection detected, using relay")
+		return ""
+	}
+	if info.Ports == nil {
+		return ""
+	}
+	for _, p := range *info.Ports {
+		if p.Ip == nil || p.Port == nil {
+			continue
+		}
+		endpoint := fmt.Sprintf("http://%s", net.JoinHostPort(*p.Ip, strconv.Itoa(*p.Port)))
+		if d.probeDirectEndpoint(ctx, s, endpoint) {
+			d.log.Info("using direct connection", "endpoint", endpoint)
+			return endpoint
+		}
+	}
+	d.log.Info("device is not reachable directly, using relay")
+	return ""
+}
+
+func (d *jDevice) probeDirectEndpoint(ctx context.Context, s sessionTokens, endpoint string) bool {
+	ctx, cancel := context.WithTimeout(ctx, directProbeTimeout)
+	defer cancel()
+	_, err := d.call(ctx, s, endpoint, "/device/ping", nil)
+	if err != nil {
+		d.log.Debug("direct connection probe failed", "endpoint", endpoint, "error", err)
+		return false
+	}
+	return true
+}
+
+// isTransportError returns true if err was caused by network rather than by API
+func isTransportError(ctx context.Context, err error) bool {
+	var apiErr *APIError
+	return err != nil && ctx.Err() == nil && !errors.As(err, &apiErr)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectConnection(t *testing.T) {
+	s, d, dev := newTestDevice(t, jdownloader.ClientOptionDirectConnection(true))
+	var directCalls atomic.Int32
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		directCalls.Add(1)
+		s.ServeHTTP(w, r)
+	}))
+	defer direct.Close()
+	host, port, _ := net.SplitHostPort(direct.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	unreachable := 1
+
+	d.Respond("/device/getDirectConnectionInfos", &jdownloader.DirectConnectionInfo{
+		Ports: &[]jdownloader.DirectConnectionPort{{Ip: &host, Port: &unreachable}, {Ip: &host, Port: &portNum}},
+	})
+	d.Respond("/downloadcontroller/getSpeedInBps", 1024)
+	speed, err := dev.Downloader().Speed(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1024), *speed.Speed)
+	// ping + actual call
+	assert.Equal(t, int32(2), directCalls.Load())
+
+	// direct connection goes down, request must be relayed
+	direct.Close()
+	for i := 0; i < 2; i++ {
+		speed, err = dev.Downloader().Speed(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, float64(1024), *speed.Speed)
+	}
+	assert.Equal(t, int32(2), directCalls.Load())
+	assert.Equal(t, 3, s.Calls("/downloadcontroller/getSpeedInBps"))
+	assert.Equal(t, 1, s.Calls("/device/getDirectConnectionInfos"))
+}
+
+func TestDirectConnectionRebindProtection(t *testing.T) {
+	s, d, dev := newTestDevice(t, jdownloader.ClientOptionDirectConnection(true))
+	host, port, yes := "127.0.0.1", 1, true
+	d.Respond("/device/getDirectConnectionInfos", &jdownloader.DirectConnectionInfo{
+		Ports:                    &[]jdownloader.DirectConnectionPort{{Ip: &host, Port: &port}},
+		RebindProtectionDetected: &yes,
+	})
+	d.Respond("/downloadcontroller/getCurrentState", "IDLE")
+	_, err := dev.Downloader().State(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, s.Calls("/device/ping"))
+}
+
+func TestDirectConnectionSharedDiscovery(t *testing.T) {
+	s := newTestServer(t)
+	d := s.AddDevice("dev1", "dev1")
+	var directCalls atomic.Int32
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		directCalls.Add(1)
+		s.ServeHTTP(w, r)
+	}))
+	defer direct.Close()
+	host, port, _ := net.SplitHostPort(direct.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	d.Handle("/device/getDirectConnectionInfos", func([]json.RawMessage) (interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return &jdownloader.DirectConnectionInfo{
+			Ports: &[]jdownloader.DirectConnectionPort{{Ip: &host, Port: &portNum}},
+		}, nil
+	})
+	d.Respond("/downloadcontroller/getSpeedInBps", 1024)
+	c := newTestClient(s, jdownloader.ClientOptionDirectConnection(true))
+	dev, err := c.Device(t.Context(), "dev1")
+	assert.NoError(t, err)
+
+	// caller's deadline expires while discovery is still running
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err = dev.Downloader().Speed(ctx)
+	assert.Error(t, err)
+
+	// discovery finishes anyway and is shared by new device handle
+	assert.Eventually(t, func() bool {
+		return directCalls.Load() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	dev, err = c.Device(t.Context(), "dev1")
+	assert.NoError(t, err)
+	_, err = dev.Downloader().Speed(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), directCalls.Load())
+	assert.Equal(t, 1, s.Calls("/device/getDirectConnectionInfos"))
+}
@@ -17,10 +17,44 @@ limitations under the License.
 package jdownloader
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 )
 
+// Priority of link or package, as used in Priority fields of links and packages
+type Priority string
+
+const (
+	PriorityHighest = Priority("HIGHEST")
+	PriorityHigher  = Priority("HIGHER")
+	PriorityHigh    = Priority("HIGH")
+	PriorityDefault = Priority("DEFAULT")
+	PriorityLow     = Priority("LOW")
+	PriorityLower   = Priority("LOWER")
+	PriorityLowest  = Priority("LOWEST")
+)
+
+// UrlDisplayType selects which URL of link is returned
+type UrlDisplayType string
+
+const (
+	UrlDisplayTypeCustom    = UrlDisplayType("CUSTOM")
+	UrlDisplayTypeReferrer  = UrlDisplayType("REFERRER")
+	UrlDisplayTypeOrigin    = UrlDisplayType("ORIGIN")
+	UrlDisplayTypeContainer = UrlDisplayType("CONTAINER")
+	UrlDisplayTypeContent   = UrlDisplayType("CONTENT")
+)
+
+// StopMark is link or package after which download process stops.
+// Exactly one of Link and Package is set.
+type StopMark struct {
+	Uuid    int64
+	Link    *DownloadLink
+	Package *DownloadPackage
+}
+
 type DownloadState struct {
 	State *string `json:"state,omitempty"`
 }
@@ -45,7 +79,7 @@ type DownloadQueryLinksParams struct {
 	Running          *bool    `json:"running,omitempty"`
 	Skipped          *bool    `json:"skipped,omitempty"`
 	ExtractionStatus *bool    `json:"extractionStatus,omitempty"`
-	PackageUUIDs     *[]int64 `json:"packageUUIDs"`
+	PackageUUIDs     *[]int64 `json:"packageUUIDs,omitempty"`
 	Url              *bool    `json:"url"`
 	Priority         *bool    `json:"priority"`
 }
@@ -72,6 +106,147 @@ func DefaultDownloadQueryLinksOptions() DownloadQueryLinksOptions {
 	}
 }
 
+func DownloadQueryLinksOptionPackageUUIDs(uuids ...int64) DownloadQueryLinksOptions {
+	return func(params *DownloadQueryLinksParams) {
+		params.PackageUUIDs = &uuids
+	}
+}
+
+type DownloadQueryPackagesParams struct {
+	BytesLoaded  *bool    `json:"bytesLoaded,omitempty"`
+	BytesTotal   *bool    `json:"bytesTotal,omitempty"`
+	ChildCount   *bool    `json:"childCount,omitempty"`
+	Comment      *bool    `json:"comment,omitempty"`
+	Enabled      *bool    `json:"enabled,omitempty"`
+	Eta          *bool    `json:"eta,omitempty"`
+	Finished     *bool    `json:"finished,omitempty"`
+	Hosts        *bool    `json:"hosts,omitempty"`
+	MaxResults   *int     `json:"maxResults,omitempty"`
+	PackageUUIDs *[]int64 `json:"packageUUIDs,omitempty"`
+	Priority     *bool    `json:"priority,omitempty"`
+	Running      *bool    `json:"running,omitempty"`
+	SaveTo       *bool    `json:"saveTo,omitempty"`
+	Speed        *bool    `json:"speed,omitempty"`
+	StartAt      *int     `json:"startAt,omitempty"`
+	Status       *bool    `json:"status,omitempty"`
+}
+
+type DownloadQueryPackagesOptions func(params *DownloadQueryPackagesParams)
+
+func DefaultDownloadQueryPackagesOptions() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.BytesLoaded = &yes
+		params.BytesTotal = &yes
+		params.ChildCount = &yes
+		params.Comment = &yes
+		params.Enabled = &yes
+		params.Eta = &yes
+		params.Finished = &yes
+		params.Hosts = &yes
+		params.Priority = &yes
+		params.Running = &yes
+		params.SaveTo = &yes
+		params.Speed = &yes
+		params.Status = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionBytesLoaded() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.BytesLoaded = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionBytesTotal() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.BytesTotal = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionChildCount() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.ChildCount = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionComment() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Comment = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionEnabled() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Enabled = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionEta() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Eta = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionFinished() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Finished = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionHosts() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Hosts = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionMaxResults(maxResults int) DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.MaxResults = &maxResults
+	}
+}
+
+func DownloadQueryPackagesOptionPackageUUIDs(uuids ...int64) DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.PackageUUIDs = &uuids
+	}
+}
+
+func DownloadQueryPackagesOptionPriority() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Priority = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionRunning() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Running = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionSaveTo() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.SaveTo = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionSpeed() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Speed = &yes
+	}
+}
+
+func DownloadQueryPackagesOptionStartAt(startAt int) DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.StartAt = &startAt
+	}
+}
+
+func DownloadQueryPackagesOptionStatus() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.Status = &yes
+	}
+}
+
 type DownloadLink struct {
 	AddedDate        *int64   `json:"addedDate,omitempty"`
 	BytesTotal       *int64   `json:"bytesTotal,omitempty"`
@@ -87,6 +262,7 @@ type DownloadLink struct {
 	Name             *string  `json:"name,omitempty"`
 	PackageUuid      *int64   `json:"packageUUID,omitempty"`
 	Priority         *string  `json:"priority"`
+	Running          *bool    `json:"running,omitempty"`
 	Skipped          *bool    `json:"skipped,omitempty"`
 	Speed            *float64 `json:"speed,omitempty"`
 	Status           *string  `json:"status,omitempty"`
@@ -118,23 +294,62 @@ type DownloadPackage struct {
 
 type Downloader interface {
 	// Packages queries information about existing packages
-	Packages(...LinkGrabberQueryPackagesOptions) (*[]DownloadPackage, error)
+	Packages(context.Context, ...DownloadQueryPackagesOptions) (*[]DownloadPackage, error)
 	// Links queries information about existing links
-	Links(...DownloadQueryLinksOptions) (*[]DownloadLink, error)
+	Links(context.Context, ...DownloadQueryLinksOptions) (*[]DownloadLink, error)
 	// Remove removes given links and/or packages
-	Remove([]int64, []int64) error
+	Remove(context.Context, []int64, []int64) error
 	// Start starts download process
-	Start() (bool, error)
+	Start(context.Context) (bool, error)
 	// Stop stops download process
-	Stop() (bool, error)
+	Stop(context.Context) (bool, error)
 	// Pause pauses download process
-	Pause() (bool, error)
+	Pause(context.Context) (bool, error)
 	// Speed get current download speed
-	Speed() (*DownloadSpeedInfo, error)
+	Speed(context.Context) (*DownloadSpeedInfo, error)
 	// Force forces download of given links/packages
-	Force([]int64, []int64) error
+	Force(context.Context, []int64, []int64) error
 	// State gets current state of download process
-	State() (*DownloadState, error)
+	State(context.Context) (*DownloadState, error)
+	// RenamePackage renames package
+	RenamePackage(context.Context, int64, string) error
+	// RenameLink renames link
+	RenameLink(context.Context, int64, string) error
+	// MovePackages moves given packages after package with given UUID
+	MovePackages(ctx context.Context, packageIds []int64, afterPackageId int64) error
+	// MoveLinks moves given links into destination package, after link with given UUID
+	MoveLinks(ctx context.Context, linkIds []int64, afterLinkId int64, destPackageId int64) error
+	// MoveToNewPackage moves given links/packages into newly created package
+	MoveToNewPackage(ctx context.Context, linkIds []int64, packageIds []int64, name string, downloadPath string) error
+	// SetEnabled enables or disables given links/packages
+	SetEnabled(ctx context.Context, enabled bool, linkIds []int64, packageIds []int64) error
+	// SetPriority sets priority of given links/packages
+	SetPriority(ctx context.Context, priority Priority, linkIds []int64, packageIds []int64) error
+	// SetDownloadDirectory changes download directory of given packages
+	SetDownloadDirectory(ctx context.Context, directory string, packageIds []int64) error
+	// SetDownloadPassword sets download password of given links/packages
+	SetDownloadPassword(ctx context.Context, linkIds []int64, packageIds []int64, password string) error
+	// ResetLinks resets given links/packages, so they are downloaded again
+	ResetLinks(ctx context.Context, linkIds []int64, packageIds []int64) error
+	// ResumeLinks resumes given failed links/packages
+	ResumeLinks(ctx context.Context, linkIds []int64, packageIds []int64) error
+	// SplitPackageByHoster splits given links/packages into new packages, one per hoster
+	SplitPackageByHoster(ctx context.Context, linkIds []int64, packageIds []int64) error
+	// DownloadUrls gets URLs of given links/packages, mapped to UUIDs of links sharing the same URL
+	DownloadUrls(ctx context.Context, linkIds []int64, packageIds []int64, types ...UrlDisplayType) (map[string][]int64, error)
+	// Cleanup removes links matching action from selection
+	Cleanup(ctx context.Context, linkIds []int64, packageIds []int64, action CleanupAction, mode CleanupMode, selection CleanupSelection) error
+	// SetStopMark sets stop mark on link or package. Exactly one of UUIDs must be non-zero.
+	SetStopMark(ctx context.Context, linkId int64, packageId int64) error
+	// RemoveStopMark removes stop mark, if any
+	RemoveStopMark(context.Context) error
+	// StopMark gets current stop mark, or nil if there is none
+	StopMark(context.Context) (*StopMark, error)
+	// StopMarkedLink gets link with stop mark, or nil if stop mark is not set on link
+	StopMarkedLink(context.Context) (*DownloadLink, error)
+	// StructureChangeCounter gets counter of structural changes of download list.
+	// It returns -1 if counter still equals to given value.
+	StructureChangeCounter(ctx context.Context, old int64) (int64, error)
 }
 
 type downloadController struct {
@@ -149,7 +364,7 @@ func newDownloadController(log *slog.Logger, d *jDevice) Downloader {
 	}
 }
 
-func (dc *downloadController) Links(options ...DownloadQueryLinksOptions) (*[]DownloadLink, error) {
+func (dc *downloadController) Links(ctx context.Context, options ...DownloadQueryLinksOptions) (*[]DownloadLink, error) {
 	params := &DownloadQueryLinksParams{}
 	if len(options) == 0 {
 		defaults := DefaultDownloadQueryLinksOptions()
@@ -158,7 +373,7 @@ func (dc *downloadController) Links(options ...DownloadQueryLinksOptions) (*[]Do
 	for _, opt := range options {
 		opt(params)
 	}
-	data, err := dc.d.doDevice("/downloadsV2/queryLinks", true, params)
+	data, err := dc.d.doDevice(ctx, "/downloadsV2/queryLinks", true, params)
 	if err != nil {
 		return nil, err
 	}
@@ -170,15 +385,15 @@ func (dc *downloadController) Links(options ...DownloadQueryLinksOptions) (*[]Do
 	return &items, nil
 }
 
-func (dc *downloadController) Packages(options ...LinkGrabberQueryPackagesOptions) (*[]DownloadPackage, error) {
-	params := &QueryPackagesParams{}
+func (dc *downloadController) Packages(ctx context.Context, options ...DownloadQueryPackagesOptions) (*[]DownloadPackage, error) {
+	params := &DownloadQueryPackagesParams{}
 	if len(options) == 0 {
-		options = append(options, QueryPackagesOptionDefault())
+		options = append(options, DefaultDownloadQueryPackagesOptions())
 	}
 	for _, opt := range options {
 		opt(params)
 	}
-	data, err := dc.d.doDevice("/downloadsV2/queryPackages", true, params)
+	data, err := dc.d.doDevice(ctx, "/downloadsV2/queryPackages", true, params)
 	if err != nil {
 		return nil, err
 	}
@@ -190,32 +405,32 @@ func (dc *downloadController) Packages(options ...LinkGrabberQueryPackagesOption
 	return &items, nil
 }
 
-func (dc *downloadController) Start() (bool, error) {
-	data, err := dc.d.doDevice("/downloadcontroller/start", false)
+func (dc *downloadController) Start(ctx context.Context) (bool, error) {
+	data, err := dc.d.doDevice(ctx, "/downloadcontroller/start", false)
 	if err != nil {
 		return false, err
 	}
 	return data.Data.(bool), err
 }
 
-func (dc *downloadController) Stop() (bool, error) {
-	data, err := dc.d.doDevice("/downloadcontroller/stop", false)
+func (dc *downloadController) Stop(ctx context.Context) (bool, error) {
+	data, err := dc.d.doDevice(ctx, "/downloadcontroller/stop", false)
 	if err != nil {
 		return false, err
 	}
 	return data.Data.(bool), err
 }
 
-func (dc *downloadController) Pause() (bool, error) {
-	data, err := dc.d.doDevice("/downloadcontroller/pause", false)
+func (dc *downloadController) Pause(ctx context.Context) (bool, error) {
+	data, err := dc.d.doDevice(ctx, "/downloadcontroller/pause", false)
 	if err != nil {
 		return false, err
 	}
 	return data.Data.(bool), err
 }
 
-func (dc *downloadController) Speed() (*DownloadSpeedInfo, error) {
-	data, err := dc.d.doDevice("/downloadcontroller/getSpeedInBps", false)
+func (dc *downloadController) Speed(ctx context.Context) (*DownloadSpeedInfo, error) {
+	data, err := dc.d.doDevice(ctx, "/downloadcontroller/getSpeedInBps", false)
 	if err != nil {
 		return nil, err
 	}
@@ -223,24 +438,24 @@ func (dc *downloadController) Speed() (*DownloadSpeedInfo, error) {
 	return &DownloadSpeedInfo{Speed: &speed}, nil
 }
 
-func (dc *downloadController) Force(linkIds []int64, packageIds []int64) error {
+func (dc *downloadController) Force(ctx context.Context, linkIds []int64, packageIds []int64) error {
 	if len(linkIds) == 0 && len(packageIds) == 0 {
 		return errors.New("one of linkIds or packageIds must not be empty")
 	}
-	_, err := dc.d.doDevice("/downloadcontroller/forceDownload", false, linkIds, packageIds)
+	_, err := dc.d.doDevice(ctx, "/downloadcontroller/forceDownload", false, uuids(linkIds), uuids(packageIds))
 	return err
 }
 
-func (dc *downloadController) Remove(linkIds []int64, packageIds []int64) error {
+func (dc *downloadController) Remove(ctx context.Context, linkIds []int64, packageIds []int64) error {
 	if len(linkIds) == 0 && len(packageIds) == 0 {
 		return errors.New("one of linkIds or packageIds must not be empty")
 	}
-	_, err := dc.d.doDevice("/downloadsV2/removeLinks", false, linkIds, packageIds)
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/removeLinks", false, uuids(linkIds), uuids(packageIds))
 	return err
 }
 
-func (dc *downloadController) State() (*DownloadState, error) {
-	data, err := dc.d.doDevice("/downloadcontroller/getCurrentState", false)
+func (dc *downloadController) State(ctx context.Context) (*DownloadState, error) {
+	data, err := dc.d.doDevice(ctx, "/downloadcontroller/getCurrentState", false)
 	if err != nil {
 		return nil, err
 	}
@@ -248,4 +463,184 @@ func (dc *downloadController) State() (*DownloadState, error) {
 	return &DownloadState{State: &state}, nil
 }
 
+func (dc *downloadController) RenamePackage(ctx context.Context, id int64, name string) error {
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/renamePackage", false, id, name)
+	return err
+}
+
+func (dc *downloadController) RenameLink(ctx context.Context, id int64, name string) error {
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/renameLink", false, id, name)
+	return err
+}
+
+func (dc *downloadController) MovePackages(ctx context.Context, packageIds []int64, afterPackageId int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/movePackages", false, uuids(packageIds), afterPackageId)
+	return err
+}
+
+func (dc *downloadController) MoveLinks(ctx context.Context, linkIds []int64, afterLinkId int64, destPackageId int64) error {
+	if len(linkIds) == 0 {
+		return errors.New("linkIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/moveLinks", false, uuids(linkIds), afterLinkId, destPackageId)
+	return err
+}
+
+func (dc *downloadController) MoveToNewPackage(ctx context.Context, linkIds []int64, packageIds []int64, name string, downloadPath string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/movetoNewPackage", false, uuids(linkIds), uuids(packageIds), name, downloadPath)
+	return err
+}
+
+func (dc *downloadController) SetEnabled(ctx context.Context, enabled bool, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/setEnabled", false, enabled, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (dc *downloadController) SetPriority(ctx context.Context, priority Priority, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/setPriority", false, priority, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (dc *downloadController) SetDownloadDirectory(ctx context.Context, directory string, packageIds []int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/setDownloadDirectory", false, directory, uuids(packageIds))
+	return err
+}
+
+func (dc *downloadController) SetDownloadPassword(ctx context.Context, linkIds []int64, packageIds []int64, password string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/setDownloadPassword", false, uuids(linkIds), uuids(packageIds), password)
+	return err
+}
+
+func (dc *downloadController) ResetLinks(ctx context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/resetLinks", false, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (dc *downloadController) ResumeLinks(ctx context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/resumeLinks", false, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (dc *downloadController) SplitPackageByHoster(ctx context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/splitPackageByHoster", false, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (dc *downloadController) DownloadUrls(ctx context.Context, linkIds []int64, packageIds []int64, types ...UrlDisplayType) (map[string][]int64, error) {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return nil, errors.New("one of linkIds or packageIds must not be empty")
+	}
+	if len(types) == 0 {
+		types = []UrlDisplayType{UrlDisplayTypeContent}
+	}
+	data, err := dc.d.doDevice(ctx, "/downloadsV2/getDownloadUrls", false, uuids(linkIds), uuids(packageIds), types)
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string][]int64)
+	err = toObj(data, &urls)
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (dc *downloadController) Cleanup(ctx context.Context, linkIds []int64, packageIds []int64,
+	action CleanupAction, mode CleanupMode, selection CleanupSelection) error {
+	return cleanup(ctx, "downloadsV2", dc.d, linkIds, packageIds, action, mode, selection)
+}
+
+func (dc *downloadController) SetStopMark(ctx context.Context, linkId int64, packageId int64) error {
+	if (linkId == 0) == (packageId == 0) {
+		return errors.New("exactly one of linkId or packageId must be set")
+	}
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/setStopMark", false, linkId, packageId)
+	return err
+}
+
+func (dc *downloadController) RemoveStopMark(ctx context.Context) error {
+	_, err := dc.d.doDevice(ctx, "/downloadsV2/removeStopMark", false)
+	return err
+}
+
+func (dc *downloadController) StopMark(ctx context.Context) (*StopMark, error) {
+	data, err := dc.d.doDevice(ctx, "/downloadsV2/getStopMark", false)
+	if err != nil {
+		return nil, err
+	}
+	var uuid int64
+	if err = toObj(data, &uuid); err != nil {
+		return nil, err
+	}
+	if uuid <= 0 {
+		return nil, nil
+	}
+	mark := &StopMark{Uuid: uuid}
+	link, err := dc.StopMarkedLink(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if link != nil && link.Uuid != nil && *link.Uuid == uuid {
+		mark.Link = link
+		return mark, nil
+	}
+	pkgs, err := dc.Packages(ctx, DefaultDownloadQueryPackagesOptions(), DownloadQueryPackagesOptionPackageUUIDs(uuid))
+	if err != nil {
+		return nil, err
+	}
+	for i := range *pkgs {
+		if p := (*pkgs)[i]; p.Uuid != nil && *p.Uuid == uuid {
+			mark.Package = &p
+			return mark, nil
+		}
+	}
+	return nil, fmt.Errorf("stop mark %d not found", uuid)
+}
+
+func (dc *downloadController) StopMarkedLink(ctx context.Context) (*DownloadLink, error) {
+	data, err := dc.d.doDevice(ctx, "/downloadsV2/getStopMarkedLink", false)
+	if err != nil {
+		return nil, err
+	}
+	if data.Data == nil {
+		return nil, nil
+	}
+	link := &DownloadLink{}
+	if err = toObj(data, link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (dc *downloadController) StructureChangeCounter(ctx context.Context, old int64) (int64, error) {
+	return changeCounter(ctx, dc.d, "/downloadsV2/getStructureChangeCounter", old)
+}
+
 var _ Downloader = &downloadController{}
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloaderManipulation(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	d.Handle("/downloadsV2/setPriority", func(params []json.RawMessage) (interface{}, error) {
+		var priority string
+		var linkIds, packageIds []int64
+		assert.NoError(t, jdtest.DecodeParam(params[0], &priority))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &linkIds))
+		assert.NoError(t, jdtest.DecodeParam(params[2], &packageIds))
+		assert.Equal(t, "HIGHEST", priority)
+		assert.Equal(t, []int64{1, 2}, linkIds)
+		assert.Empty(t, packageIds)
+		return nil, nil
+	})
+	d.Handle("/downloadsV2/moveLinks", func(params []json.RawMessage) (interface{}, error) {
+		var linkIds []int64
+		var after, dest int64
+		assert.NoError(t, jdtest.DecodeParam(params[0], &linkIds))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &after))
+		assert.NoError(t, jdtest.DecodeParam(params[2], &dest))
+		assert.Equal(t, []int64{3}, linkIds)
+		assert.Equal(t, int64(1), after)
+		assert.Equal(t, int64(10), dest)
+		return nil, nil
+	})
+	d.Handle("/downloadsV2/movetoNewPackage", func(params []json.RawMessage) (interface{}, error) {
+		var linkIds []int64
+		var name, path string
+		assert.NoError(t, jdtest.DecodeParam(params[0], &linkIds))
+		assert.NoError(t, jdtest.DecodeParam(params[2], &name))
+		assert.NoError(t, jdtest.DecodeParam(params[3], &path))
+		assert.Equal(t, []int64{4}, linkIds)
+		assert.Equal(t, "new", name)
+		assert.Equal(t, "/mnt", path)
+		return nil, nil
+	})
+	d.Respond("/downloadsV2/getDownloadUrls", map[string][]int64{"https://host.tld/a.zip": {1, 2}})
+	dl := dev.Downloader()
+
+	assert.NoError(t, dl.SetPriority(t.Context(), jdownloader.PriorityHighest, []int64{1, 2}, []int64{}))
+	assert.NoError(t, dl.MoveLinks(t.Context(), []int64{3}, 1, 10))
+	assert.NoError(t, dl.MoveToNewPackage(t.Context(), []int64{4}, nil, "new", "/mnt"))
+	assert.Equal(t, 1, s.Calls("/downloadsV2/movetoNewPackage"))
+	urls, err := dl.DownloadUrls(t.Context(), []int64{1, 2}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, urls["https://host.tld/a.zip"])
+	assert.Error(t, dl.SetEnabled(t.Context(), false, nil, nil))
+	assert.Equal(t, 0, s.Calls("/downloadsV2/setEnabled"))
+}
+
+func TestMockDownloaderManipulation(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	l1, l2, l3 := watchedLink(1, 10), watchedLink(2, 10), watchedLink(3, 11)
+	l1.Host, l2.Host, l3.Host = ptr("a.tld"), ptr("b.tld"), ptr("a.tld")
+	dev.SetPackages(&[]jdownloader.DownloadPackage{{Uuid: ptr(int64(10))}, {Uuid: ptr(int64(11))}})
+	dev.SetLinks(&[]jdownloader.DownloadLink{l1, l2, l3})
+	dl := dev.Downloader()
+
+	assert.NoError(t, dl.RenamePackage(ctx, 10, "renamed"))
+	assert.NoError(t, dl.SetPriority(ctx, jdownloader.PriorityLow, nil, []int64{10}))
+	assert.NoError(t, dl.MovePackages(ctx, []int64{11}, 0))
+	pkgs, _ := dl.Packages(ctx)
+	assert.Equal(t, int64(11), *(*pkgs)[0].Uuid)
+	assert.Equal(t, "renamed", *(*pkgs)[1].Name)
+	assert.Equal(t, "LOW", *(*pkgs)[1].Priority)
+
+	assert.NoError(t, dl.MoveLinks(ctx, []int64{3}, 2, 10))
+	links, _ := dl.Links(ctx)
+	assert.Equal(t, int64(3), *(*links)[2].Uuid)
+	assert.Equal(t, "LOW", *(*links)[0].Priority)
+	pkgs, _ = dl.Packages(ctx)
+	assert.Len(t, *pkgs, 1, "empty package should be removed")
+
+	assert.NoError(t, dl.SplitPackageByHoster(ctx, nil, []int64{10}))
+	pkgs, _ = dl.Packages(ctx)
+	assert.Len(t, *pkgs, 2)
+	assert.Equal(t, 2, *(*pkgs)[0].ChildCount)
+	assert.Equal(t, "a.tld", *(*pkgs)[0].Name)
+}
+
+func TestStopMark(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	d.Respond("/downloadsV2/getStopMark", -1)
+	dl := dev.Downloader()
+	mark, err := dl.StopMark(t.Context())
+	assert.NoError(t, err)
+	assert.Nil(t, mark)
+
+	d.Handle("/downloadsV2/setStopMark", func(params []json.RawMessage) (interface{}, error) {
+		var linkId, packageId int64
+		assert.NoError(t, jdtest.DecodeParam(params[0], &linkId))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &packageId))
+		assert.Equal(t, int64(0), linkId)
+		assert.Equal(t, int64(10), packageId)
+		d.Respond("/downloadsV2/getStopMark", packageId)
+		return nil, nil
+	})
+	d.Respond("/downloadsV2/getStopMarkedLink", nil)
+	d.Respond("/downloadsV2/queryPackages", []jdownloader.DownloadPackage{{Uuid: ptr(int64(10)), Name: ptr("pkg")}})
+	assert.Error(t, dl.SetStopMark(t.Context(), 1, 10))
+	assert.NoError(t, dl.SetStopMark(t.Context(), 0, 10))
+	mark, err = dl.StopMark(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), mark.Uuid)
+	assert.Nil(t, mark.Link)
+	assert.Equal(t, "pkg", *mark.Package.Name)
+}
+
+func TestMockStopMark(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	dev.SetLinks(&[]jdownloader.DownloadLink{watchedLink(1, 10)})
+	dl := dev.Downloader()
+	assert.Error(t, dl.SetStopMark(ctx, 2, 0))
+	assert.NoError(t, dl.SetStopMark(ctx, 1, 0))
+	mark, err := dl.StopMark(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *mark.Link.Uuid)
+	assert.NoError(t, dl.RemoveStopMark(ctx))
+	mark, _ = dl.StopMark(ctx)
+	assert.Nil(t, mark)
+}
+
+func TestDownloadPackagesQuery(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	d.Handle("/downloadsV2/queryPackages", func(params []json.RawMessage) (interface{}, error) {
+		query := map[string]interface{}{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], &query))
+		if _, filtered := query["packageUUIDs"]; filtered {
+			assert.Equal(t, map[string]interface{}{"packageUUIDs": []interface{}{float64(10)}, "running": true}, query)
+		} else {
+			for _, field := range []string{"running", "finished", "speed", "eta", "bytesLoaded"} {
+				assert.Equal(t, true, query[field], field)
+			}
+		}
+		return []jdownloader.DownloadPackage{{Uuid: ptr(int64(10)), Running: ptr(true)}}, nil
+	})
+	_, err := dev.Downloader().Packages(t.Context())
+	assert.NoError(t, err)
+	pkgs, err := dev.Downloader().Packages(t.Context(),
+		jdownloader.DownloadQueryPackagesOptionPackageUUIDs(10), jdownloader.DownloadQueryPackagesOptionRunning())
+	assert.NoError(t, err)
+	assert.True(t, *(*pkgs)[0].Running)
+}
+
+func TestNilIdsSentAsEmptyArray(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	for _, action := range []string{"/downloadsV2/removeLinks", "/linkgrabberv2/moveToDownloadlist"} {
+		d.Handle(action, func(params []json.RawMessage) (interface{}, error) {
+			assert.Equal(t, "[]", string(params[0]))
+			assert.Equal(t, "[5]", string(params[1]))
+			return nil, nil
+		})
+	}
+	assert.NoError(t, dev.Downloader().Remove(t.Context(), nil, []int64{5}))
+	assert.NoError(t, dev.LinkGrabber().MoveToDownloadList(t.Context(), nil, []int64{5}))
+}
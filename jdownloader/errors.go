@@ -0,0 +1,130 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// ErrorSource identifies which party produced an API error
+type ErrorSource string
+
+const (
+	// ErrorSourceServer is used for errors reported by MyJDownloader API server
+	ErrorSourceServer ErrorSource = "MYJD"
+	// ErrorSourceDevice is used for errors reported by JDownloader device
+	ErrorSourceDevice ErrorSource = "DEVICE"
+)
+
+// ErrorType is type of error as reported in "type" field of error response
+type ErrorType string
+
+const (
+	ErrorTypeTokenInvalid        ErrorType = "TOKEN_INVALID"
+	ErrorTypeAuthFailed          ErrorType = "AUTH_FAILED"
+	ErrorTypeEmailNotConfirmed   ErrorType = "EMAIL_NOT_CONFIRMED"
+	ErrorTypeOffline             ErrorType = "OFFLINE"
+	ErrorTypeTooManyRequests     ErrorType = "TOO_MANY_REQUESTS"
+	ErrorTypeOverload            ErrorType = "OVERLOAD"
+	ErrorTypeMaintenance         ErrorType = "MAINTENANCE"
+	ErrorTypeBadParameters       ErrorType = "BAD_PARAMETERS"
+	ErrorTypeApiCommandNotFound  ErrorType = "API_COMMAND_NOT_FOUND"
+	ErrorTypeInternalServerError ErrorType = "INTERNAL_SERVER_ERROR"
+	ErrorTypeUnknown             ErrorType = "UNKNOWN"
+)
+
+var (
+	// ErrTokenInvalid matches errors caused by expired or otherwise invalid session token
+	ErrTokenInvalid = &APIError{Type: ErrorTypeTokenInvalid}
+	// ErrAuthFailed matches errors caused by invalid credentials
+	ErrAuthFailed = &APIError{Type: ErrorTypeAuthFailed}
+	// ErrEmailNotConfirmed matches errors caused by account which email was not confirmed yet
+	ErrEmailNotConfirmed = &APIError{Type: ErrorTypeEmailNotConfirmed}
+	// ErrOffline matches errors caused by device not being connected to API server
+	ErrOffline = &APIError{Type: ErrorTypeOffline}
+	// ErrTooManyRequests matches errors caused by rate-limiting on API server
+	ErrTooManyRequests = &APIError{Type: ErrorTypeTooManyRequests}
+	// ErrOverload matches errors caused by API server being overloaded
+	ErrOverload = &APIError{Type: ErrorTypeOverload}
+	// ErrMaintenance matches errors caused by API server being under maintenance
+	ErrMaintenance = &APIError{Type: ErrorTypeMaintenance}
+	// ErrDeviceException matches any error reported by device itself
+	ErrDeviceException = &APIError{Source: ErrorSourceDevice}
+)
+
+// APIError is returned whenever API server or device responds with non-OK status
+type APIError struct {
+	// StatusCode is HTTP status code of response
+	StatusCode int
+	// Type is type of error as reported in response, if any
+	Type ErrorType
+	// Source identifies whether error was produced by API server or by device
+	Source ErrorSource
+	// Action is API path or device action that failed
+	Action string
+	// RequestID is RID of failed request
+	RequestID int64
+	// Data is content of "data" field of error response, if any
+	Data interface{}
+	// Payload is raw (decrypted, if possible) body of response
+	Payload []byte
+}
+
+func (e *APIError) Error() string {
+	t := e.Type
+	if t == "" {
+		t = ErrorTypeUnknown
+	}
+	msg := fmt.Sprintf("API call %s failed (source: %s, status: %d, rid: %d): %s",
+		e.Action, e.Source, e.StatusCode, e.RequestID, t)
+	if e.Data != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Data)
+	}
+	return msg
+}
+
+// Is reports whether target is *APIError and all its non-empty fields (Type, Source, StatusCode) match this error.
+// This allows to use sentinel values such as ErrTokenInvalid with errors.Is.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok {
+		return false
+	}
+	return (t.Type == "" || t.Type == e.Type) &&
+		(t.Source == "" || t.Source == e.Source) &&
+		(t.StatusCode == 0 || t.StatusCode == e.StatusCode)
+}
+
+func newAPIError(call *apiCall, status int, body []byte, log *slog.Logger) *APIError {
+	resp, payload := parseError(body, call.key, log)
+	e := &APIError{
+		StatusCode: status,
+		Source:     call.source,
+		Action:     call.action,
+		RequestID:  call.rid,
+		Payload:    payload,
+	}
+	if resp != nil {
+		e.Type = ErrorType(resp.Type)
+		e.Data = resp.Data
+		if resp.Source != "" {
+			e.Source = ErrorSource(resp.Source)
+		}
+	}
+	return e
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &APIError{
+		StatusCode: http.StatusForbidden,
+		Type:       ErrorTypeTokenInvalid,
+		Source:     ErrorSourceServer,
+	})
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+	assert.NotErrorIs(t, err, ErrOverload)
+	assert.NotErrorIs(t, err, ErrDeviceException)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
+
+func TestNewAPIErrorEncrypted(t *testing.T) {
+	key := createSecret("test@acme.tld", "123456", "device")
+	ciphertext, err := encrypt([]byte(`{"src":"DEVICE","type":"API_COMMAND_NOT_FOUND","data":"x"}`), key)
+	assert.NoError(t, err)
+	e := newAPIError(&apiCall{
+		source: ErrorSourceDevice,
+		action: "/device/foo",
+		key:    key,
+		rid:    5,
+	}, http.StatusNotFound, []byte(base64.StdEncoding.EncodeToString(ciphertext)), slog.Default())
+	assert.Equal(t, ErrorTypeApiCommandNotFound, e.Type)
+	assert.Equal(t, ErrorSourceDevice, e.Source)
+	assert.Equal(t, "/device/foo", e.Action)
+	assert.Equal(t, int64(5), e.RequestID)
+	assert.Equal(t, "x", e.Data)
+	assert.Contains(t, e.Error(), "API_COMMAND_NOT_FOUND: x")
+	assert.ErrorIs(t, e, ErrDeviceException)
+}
+
+func TestConnectReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"src":"MYJD","type":"AUTH_FAILED"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("test@acme.tld", "123456", slog.Default(), ClientOptionApiEndpoint(srv.URL))
+	err := c.Connect(t.Context())
+	assert.ErrorIs(t, err, ErrAuthFailed)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "/my/connect", apiErr.Action)
+	assert.Equal(t, ErrorSourceServer, apiErr.Source)
+	assert.Equal(t, int64(1), apiErr.RequestID)
+}
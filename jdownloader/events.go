@@ -0,0 +1,409 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType is category of event, derived from its publisher
+type EventType string
+
+const (
+	// EventTypeDownloadController is used for changes of download controller state (running, paused, stopped, ...)
+	EventTypeDownloadController EventType = "downloadwatchdog"
+	// EventTypeDownloads is used for changes of links and packages in download list
+	EventTypeDownloads EventType = "downloads"
+	// EventTypeLinkGrabber is used for changes of links and packages in link grabber
+	EventTypeLinkGrabber EventType = "linkgrabberv2"
+	// EventTypeCaptcha is used for captcha challenges
+	EventTypeCaptcha EventType = "captchas"
+	// EventTypeOther is used for events of any other publisher
+	EventTypeOther EventType = ""
+)
+
+const (
+	defaultEventPollTimeout = 10 * time.Second
+	defaultEventKeepAlive   = 60 * time.Second
+	eventResubscribeDelay   = 5 * time.Second
+)
+
+// Event is single event received from device's event bus
+type Event struct {
+	// Type is category of event
+	Type EventType `json:"-"`
+	// Publisher is name of publisher as reported by device
+	Publisher string `json:"publisher"`
+	// ID identifies kind of event within publisher, e.g. "LINK_UPDATE.bytesLoaded"
+	ID string `json:"eventid"`
+	// Data is raw payload of event
+	Data json.RawMessage `json:"eventData,omitempty"`
+}
+
+// DecodeData unmarshals event payload into v
+func (e *Event) DecodeData(v interface{}) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+// LinkEventData is payload of link and package update events
+type LinkEventData struct {
+	Uuid        *int64  `json:"uuid,omitempty"`
+	PackageUuid *int64  `json:"packageUUID,omitempty"`
+	Name        *string `json:"name,omitempty"`
+	Status      *string `json:"status,omitempty"`
+	BytesLoaded *int64  `json:"bytesLoaded,omitempty"`
+	BytesTotal  *int64  `json:"bytesTotal,omitempty"`
+	Finished    *bool   `json:"finished,omitempty"`
+}
+
+// EventIdDownloadControllerState is ID of download controller event sent when its state changes
+const EventIdDownloadControllerState = "RUNNING_STATE"
+
+// DownloadControllerEventData is payload of download controller state events
+type DownloadControllerEventData struct {
+	// State is new state of download controller, same as reported by Downloader.State (RUNNING, PAUSE, STOPPED, ...)
+	State string
+}
+
+// UnmarshalJSON decodes state, which device sends as plain string
+func (d *DownloadControllerEventData) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &d.State)
+}
+
+// MarshalJSON encodes state as plain string, same as device does
+func (d DownloadControllerEventData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.State)
+}
+
+// CaptchaEventData is payload of captcha events
+type CaptchaEventData struct {
+	Id       *int64  `json:"id,omitempty"`
+	Hoster   *string `json:"hoster,omitempty"`
+	Type     *string `json:"type,omitempty"`
+	Link     *int64  `json:"link,omitempty"`
+	Explain  *string `json:"explain,omitempty"`
+	Created  *int64  `json:"created,omitempty"`
+	RemoteID *int64  `json:"remoteID,omitempty"`
+}
+
+// EventPublisherInfo describes event publisher available on device
+type EventPublisherInfo struct {
+	Publisher string   `json:"publisher"`
+	EventIds  []string `json:"eventids"`
+}
+
+// EventSubscription is state of subscription as reported by device
+type EventSubscription struct {
+	SubscriptionId int64    `json:"subscriptionid"`
+	Subscribed     bool     `json:"subscribed"`
+	Subscriptions  []string `json:"subscriptions"`
+	Exclusions     []string `json:"exclusions"`
+	MaxPollTimeout int64    `json:"maxPolltimeout"`
+	MaxKeepalive   int64    `json:"maxKeepalive"`
+}
+
+type EventSubscribeParams struct {
+	// Subscriptions are regular expressions of publishers to subscribe to
+	Subscriptions []string
+	// Exclusions are regular expressions of event IDs to exclude
+	Exclusions []string
+	// PollTimeout is maximal time single listen call waits for events.
+	// It should be lower than timeout of HTTP client.
+	PollTimeout time.Duration
+	// KeepAlive is time after which device drops subscription which is not listened to
+	KeepAlive time.Duration
+	// BufferSize is capacity of events channel
+	BufferSize int
+	// RetryDelay is time to wait before subscription is renewed after failure
+	RetryDelay time.Duration
+}
+
+// matches checks event against subscriptions and exclusions
+func (p *EventSubscribeParams) matches(ev Event) bool {
+	matchAny := func(patterns []string, s string) bool {
+		for _, p := range patterns {
+			if re, err := regexp.Compile("^(?:" + p + ")$"); err == nil && re.MatchString(s) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(p.Subscriptions) == 0 || matchAny(p.Subscriptions, ev.Publisher)) && !matchAny(p.Exclusions, ev.ID)
+}
+
+type EventSubscribeOptions func(params *EventSubscribeParams)
+
+func EventSubscribeOptionPublishers(publishers ...EventType) EventSubscribeOptions {
+	return func(params *EventSubscribeParams) {
+		for _, p := range publishers {
+			params.Subscriptions = append(params.Subscriptions, string(p))
+		}
+	}
+}
+
+func EventSubscribeOptionSubscriptions(patterns ...string) EventSubscribeOptions {
+	return func(params *EventSubscribeParams) {
+		params.Subscriptions = append(params.Subscriptions, patterns...)
+	}
+}
+
+func EventSubscribeOptionExclusions(patterns ...string) EventSubscribeOptions {
+	return func(params *EventSubscribeParams) {
+		params.Exclusions = append(params.Exclusions, patterns...)
+	}
+}
+
+func EventSubscribeOptionPollTimeout(timeout time.Duration) EventSubscribeOptions {
+	return func(params *EventSubscribeParams) {
+		params.PollTimeout = timeout
+	}
+}
+
+func EventSubscribeOptionKeepAlive(keepAlive time.Duration) EventSubscribeOptions {
+	return func(params *EventSubscribeParams) {
+		params.KeepAlive = keepAlive
+	}
+}
+
+func EventSubscribeOptionBufferSize(size int) EventSubscribeOptions {
+	return func(params *EventSubscribeParams) {
+		params.BufferSize = size
+	}
+}
+
+func EventSubscribeOptionRetryDelay(delay time.Duration) EventSubscribeOptions {
+	return func(params *EventSubscribeParams) {
+		params.RetryDelay = delay
+	}
+}
+
+// newEventSubscribeParams creates parameters of subscription from given options
+func newEventSubscribeParams(options ...EventSubscribeOptions) *EventSubscribeParams {
+	params := &EventSubscribeParams{
+		PollTimeout: defaultEventPollTimeout,
+		KeepAlive:   defaultEventKeepAlive,
+		RetryDelay:  eventResubscribeDelay,
+	}
+	for _, opt := range options {
+		opt(params)
+	}
+	if len(params.Subscriptions) == 0 {
+		params.Subscriptions = []string{".*"}
+	}
+	if params.Exclusions == nil {
+		params.Exclusions = []string{}
+	}
+	return params
+}
+
+type Events interface {
+	// Publishers lists event publishers available on device
+	Publishers(context.Context) (*[]EventPublisherInfo, error)
+	// Subscribe subscribes to device's event bus and delivers events on returned channel.
+	// Subscription is renewed when it expires. Channel is closed once context is cancelled.
+	Subscribe(context.Context, ...EventSubscribeOptions) (<-chan Event, error)
+	// ChangeSubscription replaces subscriptions and exclusions of live subscription, identified by channel
+	// returned from Subscribe. Other options are ignored. New filters are kept when subscription is renewed.
+	ChangeSubscription(context.Context, <-chan Event, ...EventSubscribeOptions) error
+}
+
+// eventStream is state of live subscription created by Subscribe
+type eventStream struct {
+	lock   sync.Mutex
+	params *EventSubscribeParams
+	// sub is current subscription on device, nil while it's being renewed
+	sub *EventSubscription
+}
+
+type deviceEvents struct {
+	log *slog.Logger
+	d   *jDevice
+}
+
+func newDeviceEvents(log *slog.Logger, d *jDevice) Events {
+	return &deviceEvents{
+		log: log.With("component", "events"),
+		d:   d,
+	}
+}
+
+func (e *deviceEvents) Publishers(ctx context.Context) (*[]EventPublisherInfo, error) {
+	data, err := e.d.doDevice(ctx, "/events/listpublisher", false)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]EventPublisherInfo, 0)
+	err = toObj(data, &items)
+	if err != nil {
+		return nil, err
+	}
+	return &items, nil
+}
+
+func (e *deviceEvents) Subscribe(ctx context.Context, options ...EventSubscribeOptions) (<-chan Event, error) {
+	stream := &eventStream{params: newEventSubscribeParams(options...)}
+	if err := e.subscribe(ctx, stream); err != nil {
+		return nil, err
+	}
+	ch := make(chan Event, stream.params.BufferSize)
+	e.d.impl.addEventStream(ch, stream)
+	go e.run(ctx, stream, ch)
+	return ch, nil
+}
+
+func (e *deviceEvents) ChangeSubscription(ctx context.Context, ch <-chan Event, options ...EventSubscribeOptions) error {
+	stream := e.d.impl.eventStream(ch)
+	if stream == nil {
+		return errors.New("no such subscription")
+	}
+	params := newEventSubscribeParams(options...)
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+	stream.params.Subscriptions = params.Subscriptions
+	stream.params.Exclusions = params.Exclusions
+	if stream.sub == nil {
+		// subscription is being renewed, new filters will be used
+		return nil
+	}
+	data, err := e.d.doDevice(ctx, "/events/changesubscription", false, stream.sub.SubscriptionId,
+		params.Subscriptions, params.Exclusions)
+	if err != nil {
+		return err
+	}
+	return toObj(data, stream.sub)
+}
+
+func (e *deviceEvents) run(ctx context.Context, stream *eventStream, ch chan Event) {
+	defer close(ch)
+	defer e.d.impl.removeEventStream(ch)
+	defer func() {
+		if id, ok := stream.subscriptionId(); ok {
+			e.unsubscribe(id)
+		}
+	}()
+	for {
+		id, ok := stream.subscriptionId()
+		if !ok {
+			if err := e.subscribe(ctx, stream); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				e.log.Warn("unable to subscribe to events", "error", err)
+				if !sleepCtx(ctx, stream.params.RetryDelay) {
+					return
+				}
+				continue
+			}
+			id, _ = stream.subscriptionId()
+		}
+		events, err := e.listen(ctx, id)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			e.log.Info("listening for events failed, renewing subscription", "subscription", id, "error", err)
+			stream.lock.Lock()
+			stream.sub = nil
+			stream.lock.Unlock()
+			if !sleepCtx(ctx, stream.params.RetryDelay) {
+				return
+			}
+			continue
+		}
+		for _, ev := range events {
+			ev.Type = eventTypeOf(ev.Publisher)
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// subscriptionId gets ID of current subscription, if there is any
+func (s *eventStream) subscriptionId() (int64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.sub == nil {
+		return 0, false
+	}
+	return s.sub.SubscriptionId, true
+}
+
+// subscribe creates new subscription on device using current filters of stream.
+// Lock is held, so that filters can't change while subscription is created.
+func (e *deviceEvents) subscribe(ctx context.Context, stream *eventStream) error {
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+	params := stream.params
+	data, err := e.d.doDevice(ctx, "/events/subscribe", false, params.Subscriptions, params.Exclusions)
+	if err != nil {
+		return err
+	}
+	sub := &EventSubscription{}
+	if err = toObj(data, sub); err != nil {
+		return err
+	}
+	_, err = e.d.doDevice(ctx, "/events/setsubscriptiontimeouts", false, sub.SubscriptionId,
+		params.PollTimeout.Milliseconds(), params.KeepAlive.Milliseconds())
+	if err != nil {
+		e.unsubscribe(sub.SubscriptionId)
+		return err
+	}
+	e.log.Debug("subscribed to events", "subscription", sub.SubscriptionId)
+	stream.sub = sub
+	return nil
+}
+
+func (e *deviceEvents) listen(ctx context.Context, id int64) ([]Event, error) {
+	data, err := e.d.doDevice(ctx, "/events/listen", false, id)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0)
+	err = toObj(data, &events)
+	return events, err
+}
+
+// unsubscribe cancels subscription. It's called when subscriber's context is likely done already,
+// so separate context is used.
+func (e *deviceEvents) unsubscribe(id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := e.d.doDevice(ctx, "/events/unsubscribe", false, id); err != nil {
+		e.log.Debug("unable to unsubscribe from events", "subscription", id, "error", err)
+	}
+}
+
+func eventTypeOf(publisher string) EventType {
+	switch t := EventType(strings.ToLower(publisher)); t {
+	case EventTypeDownloadController, EventTypeDownloads, EventTypeLinkGrabber, EventTypeCaptcha:
+		return t
+	}
+	return EventTypeOther
+}
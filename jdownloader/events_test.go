@@ -0,0 +1,181 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsSubscribe(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	var subId atomic.Int64
+	d.Handle("/events/subscribe", func(params []json.RawMessage) (interface{}, error) {
+		var subs []string
+		assert.NoError(t, jdtest.DecodeParam(params[0], &subs))
+		assert.Equal(t, []string{"downloads", "linkgrabberv2"}, subs)
+		return &jdownloader.EventSubscription{SubscriptionId: subId.Add(1), Subscribed: true}, nil
+	})
+	d.Respond("/events/setsubscriptiontimeouts", nil)
+	d.Respond("/events/unsubscribe", nil)
+	// every subscription receives single event, then long-poll times out with no events
+	var delivered sync.Map
+	d.Handle("/events/listen", func(params []json.RawMessage) (interface{}, error) {
+		var id int64
+		assert.NoError(t, jdtest.DecodeParam(params[0], &id))
+		if _, loaded := delivered.LoadOrStore(id, true); loaded {
+			time.Sleep(10 * time.Millisecond)
+			return []interface{}{}, nil
+		}
+		return []map[string]interface{}{{
+			"publisher": "downloads",
+			"eventid":   "LINK_UPDATE.bytesLoaded",
+			"eventData": map[string]interface{}{"uuid": id, "bytesLoaded": 100},
+		}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	ch, err := dev.Events().Subscribe(ctx, jdownloader.EventSubscribeOptionPublishers(
+		jdownloader.EventTypeDownloads, jdownloader.EventTypeLinkGrabber),
+		jdownloader.EventSubscribeOptionRetryDelay(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	ev := <-ch
+	assert.Equal(t, jdownloader.EventTypeDownloads, ev.Type)
+	assert.Equal(t, "LINK_UPDATE.bytesLoaded", ev.ID)
+	data := &jdownloader.LinkEventData{}
+	assert.NoError(t, ev.DecodeData(data))
+	assert.Equal(t, int64(1), *data.Uuid)
+	assert.Equal(t, int64(100), *data.BytesLoaded)
+
+	d.Inject("/events/listen", 1, http.StatusBadRequest, jdownloader.ErrorTypeBadParameters)
+	ev = <-ch
+	assert.NoError(t, ev.DecodeData(data))
+	assert.Equal(t, int64(2), *data.Uuid, "expected event from renewed subscription")
+
+	cancel()
+	for range ch {
+	}
+	assert.Eventually(t, func() bool {
+		return s.Calls("/events/unsubscribe") == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestEventsChangeSubscription(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	d.Respond("/events/subscribe", &jdownloader.EventSubscription{SubscriptionId: 7, Subscribed: true})
+	d.Respond("/events/setsubscriptiontimeouts", nil)
+	d.Respond("/events/unsubscribe", nil)
+	d.Handle("/events/changesubscription", func(params []json.RawMessage) (interface{}, error) {
+		var id int64
+		var subs, excl []string
+		assert.NoError(t, jdtest.DecodeParam(params[0], &id))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &subs))
+		assert.NoError(t, jdtest.DecodeParam(params[2], &excl))
+		assert.Equal(t, int64(7), id)
+		assert.Equal(t, []string{"downloadwatchdog"}, subs)
+		assert.Equal(t, []string{}, excl)
+		return &jdownloader.EventSubscription{SubscriptionId: id, Subscribed: true, Subscriptions: subs}, nil
+	})
+	d.Handle("/events/listen", func([]json.RawMessage) (interface{}, error) {
+		time.Sleep(10 * time.Millisecond)
+		if s.Calls("/events/changesubscription") == 0 {
+			return []interface{}{}, nil
+		}
+		return []map[string]interface{}{{
+			"publisher": "downloadwatchdog",
+			"eventid":   jdownloader.EventIdDownloadControllerState,
+			"eventData": "RUNNING",
+		}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	ch, err := dev.Events().Subscribe(ctx, jdownloader.EventSubscribeOptionPublishers(jdownloader.EventTypeDownloads))
+	assert.NoError(t, err)
+	assert.NoError(t, dev.Events().ChangeSubscription(ctx, ch,
+		jdownloader.EventSubscribeOptionPublishers(jdownloader.EventTypeDownloadController)))
+	assert.Error(t, dev.Events().ChangeSubscription(ctx, make(chan jdownloader.Event)))
+
+	ev := <-ch
+	assert.Equal(t, jdownloader.EventTypeDownloadController, ev.Type)
+	data := &jdownloader.DownloadControllerEventData{}
+	assert.NoError(t, ev.DecodeData(data))
+	assert.Equal(t, "RUNNING", data.State)
+	assert.Equal(t, 1, s.Calls("/events/subscribe"))
+}
+
+func TestEventsListenFailureDelay(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	d.Respond("/events/subscribe", &jdownloader.EventSubscription{SubscriptionId: 1, Subscribed: true})
+	d.Respond("/events/setsubscriptiontimeouts", nil)
+	d.Respond("/events/unsubscribe", nil)
+	d.Handle("/events/listen", func([]json.RawMessage) (interface{}, error) {
+		return nil, &jdtest.Error{Status: http.StatusBadRequest, Type: jdownloader.ErrorTypeBadParameters}
+	})
+	ctx, cancel := context.WithCancel(t.Context())
+	ch, err := dev.Events().Subscribe(ctx, jdownloader.EventSubscribeOptionRetryDelay(100*time.Millisecond))
+	assert.NoError(t, err)
+	time.Sleep(250 * time.Millisecond)
+	cancel()
+	for range ch {
+	}
+	// initial subscription and at most two renewals
+	assert.LessOrEqual(t, s.Calls("/events/subscribe"), 3)
+}
+
+func TestMockEvents(t *testing.T) {
+	dev := newMockDevice(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	ch, err := dev.Events().Subscribe(ctx, jdownloader.EventSubscribeOptionPublishers(jdownloader.EventTypeCaptcha))
+	assert.NoError(t, err)
+	go func() {
+		dev.Emit(jdownloader.Event{Publisher: "downloads", ID: "LINK_UPDATE"})
+		dev.Emit(jdownloader.Event{Publisher: "captchas", ID: "NEW"})
+	}()
+	ev := <-ch
+	assert.Equal(t, jdownloader.EventTypeCaptcha, ev.Type)
+	cancel()
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestMockEventsChangeSubscription(t *testing.T) {
+	dev := newMockDevice(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	ch, err := dev.Events().Subscribe(ctx, jdownloader.EventSubscribeOptionPublishers(jdownloader.EventTypeCaptcha))
+	assert.NoError(t, err)
+	assert.NoError(t, dev.Events().ChangeSubscription(ctx, ch,
+		jdownloader.EventSubscribeOptionPublishers(jdownloader.EventTypeDownloadController)))
+	assert.Error(t, dev.Events().ChangeSubscription(ctx, make(chan jdownloader.Event)))
+	go func() {
+		dev.Emit(jdownloader.Event{Publisher: "captchas", ID: "NEW"})
+		dev.Emit(jdownloader.Event{Publisher: "downloadwatchdog", ID: "RUNNING_STATE"})
+	}()
+	ev := <-ch
+	assert.Equal(t, jdownloader.EventTypeDownloadController, ev.Type)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdtest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Protocol primitives are implemented independently of jdownloader package,
+// so that bugs in client's implementation are not mirrored by fake server.
+
+func createSecret(email string, password string, domain string) [32]byte {
+	return sha256.Sum256([]byte(fmt.Sprintf("%s%s%s", strings.ToLower(email), password, domain)))
+}
+
+func updateToken(newToken []byte, existing [32]byte) [32]byte {
+	return sha256.Sum256(append(existing[:], newToken...))
+}
+
+func sign(uri string, key [32]byte) string {
+	h := hmac.New(sha256.New, key[:])
+	h.Write([]byte(uri))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func encrypt(plaintext []byte, key [32]byte) (string, error) {
+	block, err := aes.NewCipher(key[16:])
+	if err != nil {
+		return "", err
+	}
+	padding := block.BlockSize() - len(plaintext)%block.BlockSize()
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, key[:16]).CryptBlocks(ciphertext, padded)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func decrypt(data []byte, key [32]byte) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key[16:])
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid ciphertext length")
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, key[:16]).CryptBlocks(plaintext, ciphertext)
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > len(plaintext) {
+		return nil, errors.New("invalid padding")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
@@ -0,0 +1,435 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jdtest provides in-process fake of MyJDownloader API server for integration tests.
+// It implements server endpoints (/my/connect, /my/reconnect, /my/listdevices, /my/disconnect)
+// and encrypted device channel (/t_<session>_<device>/...) with scriptable responses and error injection.
+package jdtest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+)
+
+// ActionHandler handles device action. Params are raw JSON values as sent by client.
+// Returned value is used as "data" field of response. Returning *Error produces error response.
+type ActionHandler func(params []json.RawMessage) (interface{}, error)
+
+// Error is error response returned by server or device
+type Error struct {
+	Status int
+	Type   jdownloader.ErrorType
+	Data   interface{}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Status, e.Type)
+}
+
+// Server is fake MyJDownloader API server
+type Server struct {
+	srv          *httptest.Server
+	lock         sync.Mutex
+	email        string
+	loginSecret  [32]byte
+	deviceSecret [32]byte
+	serverToken  [32]byte
+	deviceToken  [32]byte
+	session      string
+	regain       string
+	generation   int
+	seenRids     map[int64]bool
+	devices      []*Device
+	failures     map[string][]*Error
+	calls        map[string]int
+	connects     int
+	reconnects   int
+}
+
+// Device is fake device registered with Server
+type Device struct {
+	s        *Server
+	info     jdownloader.DeviceInfo
+	handlers map[string]ActionHandler
+	failures map[string][]*Error
+}
+
+// NewServer creates and starts fake server which accepts given credentials.
+// Server should be closed when no longer needed.
+func NewServer(email, password string) *Server {
+	s := &Server{
+		email:        strings.ToLower(email),
+		loginSecret:  createSecret(email, password, "server"),
+		deviceSecret: createSecret(email, password, "device"),
+		failures:     map[string][]*Error{},
+		calls:        map[string]int{},
+	}
+	s.srv = httptest.NewServer(s)
+	return s
+}
+
+// URL gets base URL of server, suitable for jdownloader.ClientOptionApiEndpoint
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts down server
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// AddDevice registers new online device
+func (s *Server) AddDevice(id, name string) *Device {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	d := &Device{
+		s: s,
+		info: jdownloader.DeviceInfo{
+			Id:     id,
+			Name:   name,
+			Type:   "jd",
+			Status: "ONLINE",
+		},
+		handlers: map[string]ActionHandler{
+			"/device/ping": func([]json.RawMessage) (interface{}, error) {
+				return true, nil
+			},
+		},
+		failures: map[string][]*Error{},
+	}
+	s.devices = append(s.devices, d)
+	return d
+}
+
+// Inject makes next n calls of server path (such as "/my/listdevices") or device action fail
+// with given status and error type, as if error was reported by API server
+func (s *Server) Inject(action string, n int, status int, errType jdownloader.ErrorType) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	inject(s.failures, action, n, status, errType)
+}
+
+// ExpireSession invalidates current session. Regain token remains valid.
+func (s *Server) ExpireSession() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.session = ""
+}
+
+// InvalidateRegainToken makes regain token invalid, so that client must perform full login.
+func (s *Server) InvalidateRegainToken() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.regain = ""
+}
+
+// Connects gets number of successful /my/connect calls
+func (s *Server) Connects() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connects
+}
+
+// Reconnects gets number of successful /my/reconnect calls
+func (s *Server) Reconnects() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.reconnects
+}
+
+// Calls gets number of calls of server path or device action, including failed ones
+func (s *Server) Calls(action string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls[action]
+}
+
+// Handle registers handler of device action, such as "/downloadsV2/queryLinks"
+func (d *Device) Handle(action string, fn ActionHandler) {
+	d.s.lock.Lock()
+	defer d.s.lock.Unlock()
+	d.handlers[action] = fn
+}
+
+// Respond registers static response of device action
+func (d *Device) Respond(action string, data interface{}) {
+	d.Handle(action, func([]json.RawMessage) (interface{}, error) {
+		return data, nil
+	})
+}
+
+// Inject makes next n calls of device action fail with given status and error type,
+// as if error was reported by device
+func (d *Device) Inject(action string, n int, status int, errType jdownloader.ErrorType) {
+	d.s.lock.Lock()
+	defer d.s.lock.Unlock()
+	inject(d.failures, action, n, status, errType)
+}
+
+// SetStatus changes status of device as reported by /my/listdevices
+func (d *Device) SetStatus(status string) {
+	d.s.lock.Lock()
+	defer d.s.lock.Unlock()
+	d.info.Status = status
+}
+
+// DecodeParam decodes parameter of device action into v.
+// Parameters which were sent as JSON-encoded string are unwrapped first. Null leaves v unchanged.
+func DecodeParam(raw json.RawMessage, v interface{}) error {
+	var str string
+	if string(raw) == "null" {
+		return nil
+	}
+	if _, isStr := v.(*string); !isStr && json.Unmarshal(raw, &str) == nil {
+		return json.Unmarshal([]byte(str), v)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func inject(failures map[string][]*Error, action string, n int, status int, errType jdownloader.ErrorType) {
+	for i := 0; i < n; i++ {
+		failures[action] = append(failures[action], &Error{Status: status, Type: errType})
+	}
+}
+
+// nextFailure pops next injected failure of action, if any. Caller must hold lock.
+func nextFailure(failures map[string][]*Error, action string) *Error {
+	if len(failures[action]) == 0 {
+		return nil
+	}
+	e := failures[action][0]
+	failures[action] = failures[action][1:]
+	return e
+}
+
+// ServeHTTP implements http.Handler, so that server can be also mounted elsewhere,
+// e.g. to emulate direct connection to device.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/t_") {
+		s.serveDevice(w, r)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls[r.URL.Path]++
+	if e := nextFailure(s.failures, r.URL.Path); e != nil {
+		s.fail(w, e, jdownloader.ErrorSourceServer, nil)
+		return
+	}
+	q := r.URL.Query()
+	rid, err := strconv.ParseInt(q.Get("rid"), 10, 64)
+	if err != nil {
+		s.fail(w, &Error{Status: http.StatusBadRequest, Type: jdownloader.ErrorTypeBadParameters}, jdownloader.ErrorSourceServer, nil)
+		return
+	}
+	switch r.URL.Path {
+	case "/my/connect":
+		if q.Get("email") != s.email || !s.verify(r.URL, s.loginSecret) {
+			s.fail(w, &Error{Status: http.StatusForbidden, Type: jdownloader.ErrorTypeAuthFailed}, jdownloader.ErrorSourceServer, nil)
+			return
+		}
+		s.connects++
+		s.newSession()
+		s.reply(w, map[string]interface{}{"sessiontoken": s.session, "regaintoken": s.regain, "rid": rid}, s.loginSecret)
+		s.rotate(s.loginSecret, s.deviceSecret)
+	case "/my/reconnect":
+		if s.regain == "" || q.Get("regaintoken") != s.regain || !s.verify(r.URL, s.serverToken) {
+			s.fail(w, &Error{Status: http.StatusForbidden, Type: jdownloader.ErrorTypeAuthFailed}, jdownloader.ErrorSourceServer, nil)
+			return
+		}
+		s.reconnects++
+		key := s.serverToken
+		s.newSession()
+		s.reply(w, map[string]interface{}{"sessiontoken": s.session, "regaintoken": s.regain, "rid": rid}, key)
+		s.rotate(s.serverToken, s.deviceToken)
+	case "/my/listdevices":
+		if !s.validSession(q.Get("sessiontoken"), rid) || !s.verify(r.URL, s.serverToken) {
+			s.fail(w, &Error{Status: http.StatusForbidden, Type: jdownloader.ErrorTypeTokenInvalid}, jdownloader.ErrorSourceServer, nil)
+			return
+		}
+		list := make([]jdownloader.DeviceInfo, 0, len(s.devices))
+		for _, d := range s.devices {
+			list = append(list, d.info)
+		}
+		s.reply(w, map[string]interface{}{"list": list, "rid": rid}, s.serverToken)
+	case "/my/disconnect":
+		if !s.validSession(q.Get("sessiontoken"), rid) || !s.verify(r.URL, s.serverToken) {
+			s.fail(w, &Error{Status: http.StatusForbidden, Type: jdownloader.ErrorTypeTokenInvalid}, jdownloader.ErrorSourceServer, nil)
+			return
+		}
+		s.reply(w, map[string]interface{}{"rid": rid}, s.serverToken)
+		s.session = ""
+		s.regain = ""
+	default:
+		s.fail(w, &Error{Status: http.StatusNotFound, Type: jdownloader.ErrorTypeApiCommandNotFound}, jdownloader.ErrorSourceServer, nil)
+	}
+}
+
+// serveDevice handles encrypted device call.
+// Action handler is invoked without holding lock, so that calls can be processed concurrently.
+func (s *Server) serveDevice(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/t_")
+	idx := strings.Index(path, "/")
+	if idx < 0 {
+		s.fail(w, &Error{Status: http.StatusNotFound, Type: jdownloader.ErrorTypeApiCommandNotFound}, jdownloader.ErrorSourceServer, nil)
+		return
+	}
+	target, action := path[:idx], path[idx:]
+	s.lock.Lock()
+	s.calls[action]++
+	key := s.deviceToken
+	var dev *Device
+	for _, d := range s.devices {
+		if target == s.session+"_"+d.info.Id {
+			dev = d
+		}
+	}
+	if s.session == "" || !strings.HasPrefix(target, s.session+"_") {
+		s.lock.Unlock()
+		s.fail(w, &Error{Status: http.StatusForbidden, Type: jdownloader.ErrorTypeTokenInvalid}, jdownloader.ErrorSourceServer, nil)
+		return
+	}
+	if dev == nil || dev.info.Status != "ONLINE" {
+		s.lock.Unlock()
+		s.fail(w, &Error{Status: http.StatusServiceUnavailable, Type: jdownloader.ErrorTypeOffline}, jdownloader.ErrorSourceServer, nil)
+		return
+	}
+	serverFailure := nextFailure(s.failures, action)
+	deviceFailure := nextFailure(dev.failures, action)
+	handler := dev.handlers[action]
+	s.lock.Unlock()
+	if serverFailure != nil {
+		s.fail(w, serverFailure, jdownloader.ErrorSourceServer, nil)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	plaintext, err := decrypt(body, key)
+	req := &struct {
+		Url       string            `json:"url"`
+		Params    []json.RawMessage `json:"params"`
+		RequestId int64             `json:"rid"`
+	}{}
+	if err == nil {
+		err = json.Unmarshal(plaintext, req)
+	}
+	if err != nil || req.Url != action {
+		s.fail(w, &Error{Status: http.StatusBadRequest, Type: jdownloader.ErrorTypeBadParameters}, jdownloader.ErrorSourceDevice, &key)
+		return
+	}
+	s.lock.Lock()
+	reused := s.seenRids[req.RequestId]
+	s.seenRids[req.RequestId] = true
+	s.lock.Unlock()
+	if reused {
+		s.fail(w, &Error{Status: http.StatusBadRequest, Type: jdownloader.ErrorTypeBadParameters, Data: "rid already used"},
+			jdownloader.ErrorSourceDevice, &key)
+		return
+	}
+	if deviceFailure != nil {
+		s.fail(w, deviceFailure, jdownloader.ErrorSourceDevice, &key)
+		return
+	}
+	if handler == nil {
+		s.fail(w, &Error{Status: http.StatusNotFound, Type: jdownloader.ErrorTypeApiCommandNotFound}, jdownloader.ErrorSourceDevice, &key)
+		return
+	}
+	data, err := handler(req.Params)
+	if err != nil {
+		if de, ok := err.(*Error); ok {
+			s.fail(w, de, jdownloader.ErrorSourceDevice, &key)
+		} else {
+			s.fail(w, &Error{Status: http.StatusInternalServerError, Type: jdownloader.ErrorTypeInternalServerError, Data: err.Error()},
+				jdownloader.ErrorSourceDevice, &key)
+		}
+		return
+	}
+	s.reply(w, map[string]interface{}{"data": data, "rid": req.RequestId}, key)
+}
+
+// validSession checks session token and ensures that RID was not used before. Caller must hold lock.
+func (s *Server) validSession(token string, rid int64) bool {
+	if s.session == "" || token != s.session || s.seenRids[rid] {
+		return false
+	}
+	s.seenRids[rid] = true
+	return true
+}
+
+// verify checks signature of request URL
+func (s *Server) verify(u *url.URL, key [32]byte) bool {
+	raw := u.RawQuery
+	idx := strings.LastIndex(raw, "&signature=")
+	if idx < 0 {
+		return false
+	}
+	return sign(fmt.Sprintf("%s?%s", u.Path, raw[:idx]), key) == raw[idx+len("&signature="):]
+}
+
+// newSession generates new session and regain tokens. Caller must hold lock.
+func (s *Server) newSession() {
+	s.generation++
+	s.session = hex.EncodeToString([]byte(fmt.Sprintf("session-%08d", s.generation)))
+	s.regain = hex.EncodeToString([]byte(fmt.Sprintf("regain-%08d", s.generation)))
+	s.seenRids = map[int64]bool{}
+}
+
+// rotate derives encryption tokens of new session. Caller must hold lock.
+func (s *Server) rotate(existingServer, existingDevice [32]byte) {
+	token, _ := hex.DecodeString(s.session)
+	s.serverToken = updateToken(token, existingServer)
+	s.deviceToken = updateToken(token, existingDevice)
+}
+
+func (s *Server) reply(w http.ResponseWriter, v interface{}, key [32]byte) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	payload, err := encrypt(data, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write([]byte(payload))
+}
+
+// fail writes error response. Device errors are encrypted when key is provided.
+func (s *Server) fail(w http.ResponseWriter, e *Error, src jdownloader.ErrorSource, key *[32]byte) {
+	data, _ := json.Marshal(&jdownloader.DataResponse{
+		Source: string(src),
+		Type:   string(e.Type),
+		Data:   e.Data,
+	})
+	w.WriteHeader(e.Status)
+	if key != nil {
+		payload, _ := encrypt(data, *key)
+		_, _ = w.Write([]byte(payload))
+		return
+	}
+	_, _ = w.Write(data)
+}
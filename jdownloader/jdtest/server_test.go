@@ -0,0 +1,129 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdtest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s := NewServer("user@example.com", "secret")
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestClient(s *Server) jdownloader.JdClient {
+	return jdownloader.NewClient("user@example.com", "secret", slog.Default(),
+		jdownloader.ClientOptionApiEndpoint(s.URL()))
+}
+
+// deviceCall sends raw device call with given RID and returns HTTP status and decrypted body
+func deviceCall(t *testing.T, s *Server, device, action string, rid int64) (int, []byte) {
+	s.lock.Lock()
+	session, key := s.session, s.deviceToken
+	s.lock.Unlock()
+	data, _ := json.Marshal(map[string]interface{}{"url": action, "params": []interface{}{}, "rid": rid})
+	payload, err := encrypt(data, key)
+	assert.NoError(t, err)
+	resp, err := http.Post(s.URL()+"/t_"+session+"_"+device+action, "application/aesjson-jd", strings.NewReader(payload))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	plaintext, err := decrypt(body, key)
+	assert.NoError(t, err)
+	return resp.StatusCode, plaintext
+}
+
+func TestDeviceCall(t *testing.T) {
+	s := newTestServer(t)
+	d := s.AddDevice("dev1", "dev1")
+	d.Respond("/downloadcontroller/getCurrentState", "RUNNING")
+	c := newTestClient(s)
+	assert.NoError(t, c.Connect(t.Context()))
+	dev, err := c.Device(t.Context(), "dev1")
+	assert.NoError(t, err)
+	state, err := dev.Downloader().State(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "RUNNING", *state.State)
+	assert.Equal(t, 1, s.Connects())
+	assert.Equal(t, 1, s.Calls("/downloadcontroller/getCurrentState"))
+
+	_, err = dev.Downloader().Speed(t.Context())
+	assert.True(t, errors.Is(err, &jdownloader.APIError{Type: jdownloader.ErrorTypeApiCommandNotFound}))
+
+	d.SetStatus("OFFLINE")
+	_, err = dev.Downloader().State(t.Context())
+	assert.True(t, errors.Is(err, &jdownloader.APIError{Type: jdownloader.ErrorTypeOffline}))
+}
+
+func TestDeviceCallInject(t *testing.T) {
+	s := newTestServer(t)
+	d := s.AddDevice("dev1", "dev1")
+	d.Respond("/downloadcontroller/getCurrentState", "IDLE")
+	d.Inject("/downloadcontroller/getCurrentState", 1, http.StatusBadRequest, jdownloader.ErrorTypeBadParameters)
+	c := newTestClient(s)
+	dev, err := c.Device(t.Context(), "dev1")
+	assert.NoError(t, err)
+	_, err = dev.Downloader().State(t.Context())
+	assert.True(t, errors.Is(err, &jdownloader.APIError{Type: jdownloader.ErrorTypeBadParameters}))
+	state, err := dev.Downloader().State(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "IDLE", *state.State)
+}
+
+func TestDeviceCallReusedRid(t *testing.T) {
+	s := newTestServer(t)
+	d := s.AddDevice("dev1", "dev1")
+	d.Respond("/downloadcontroller/getCurrentState", "IDLE")
+	c := newTestClient(s)
+	assert.NoError(t, c.Connect(t.Context()))
+
+	status, body := deviceCall(t, s, "dev1", "/downloadcontroller/getCurrentState", 1000)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "IDLE")
+
+	status, body = deviceCall(t, s, "dev1", "/downloadcontroller/getCurrentState", 1000)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, string(body), string(jdownloader.ErrorTypeBadParameters))
+}
+
+func TestDecodeParam(t *testing.T) {
+	var q jdownloader.DownloadQueryLinksParams
+	assert.NoError(t, DecodeParam(json.RawMessage(`"{\"maxResults\":5}"`), &q))
+	assert.Equal(t, 5, *q.MaxResults)
+
+	var ids []int64
+	assert.NoError(t, DecodeParam(json.RawMessage(`[1,2]`), &ids))
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	var str string
+	assert.NoError(t, DecodeParam(json.RawMessage(`"name"`), &str))
+	assert.Equal(t, "name", str)
+
+	ids = []int64{3}
+	assert.NoError(t, DecodeParam(json.RawMessage(`null`), &ids))
+	assert.Equal(t, []int64{3}, ids)
+}
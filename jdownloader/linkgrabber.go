@@ -17,12 +17,19 @@ limitations under the License.
 package jdownloader
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
 )
 
+// crawlerJobPollInterval is delay between checks of crawler job state in AddAndWait
+const crawlerJobPollInterval = 500 * time.Millisecond
+
 type AddLinksParams struct {
 	Links             string  `json:"links"`
 	Autostart         *bool   `json:"autostart,omitempty"`
@@ -30,6 +37,7 @@ type AddLinksParams struct {
 	DestinationFolder *string `json:"destinationFolder,omitempty"`
 	DownloadPassword  *string `json:"downloadPassword"`
 	ExtractPassword   *string `json:"extractPassword"`
+	AssignJobID       *bool   `json:"assignJobID,omitempty"`
 }
 
 type AddLinksOptions func(params *AddLinksParams)
@@ -64,27 +72,72 @@ func AddLinksOptionExtractPassword(pass string) AddLinksOptions {
 	}
 }
 
+// AddLinksOptionAssignJobID tags crawled links with job id, so they can be queried using LinkGrabberQueryLinksOptionJobUUIDs
+func AddLinksOptionAssignJobID(assign bool) AddLinksOptions {
+	return func(params *AddLinksParams) {
+		params.AssignJobID = &assign
+	}
+}
+
+// LinkCollectingJob is crawler job started by adding links or container
+type LinkCollectingJob struct {
+	Id int64 `json:"id"`
+}
+
+// CrawlerJob is state of crawler job
+type CrawlerJob struct {
+	JobId     *int64 `json:"jobId,omitempty"`
+	Checking  *bool  `json:"checking,omitempty"`
+	Crawling  *bool  `json:"crawling,omitempty"`
+	Broken    *int   `json:"broken,omitempty"`
+	Crawled   *int   `json:"crawled,omitempty"`
+	Filtered  *int   `json:"filtered,omitempty"`
+	Unhandled *int   `json:"unhandled,omitempty"`
+}
+
+// progressed checks if job has handled any link so far
+func (j CrawlerJob) progressed() bool {
+	for _, n := range []*int{j.Broken, j.Crawled, j.Filtered, j.Unhandled} {
+		if n != nil && *n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type LinkCrawlerJobsQueryParams struct {
+	CollectorInfo *bool    `json:"collectorInfo,omitempty"`
+	JobIds        *[]int64 `json:"jobIds,omitempty"`
+}
+
+// LinkCollectingResult holds links and packages produced by single crawler job
+type LinkCollectingResult struct {
+	Job      LinkCollectingJob
+	Links    []CrawledLink
+	Packages []CrawledPackage
+}
+
 type QueryPackagesParams struct {
-	AvailableOfflineCount     *bool     `json:"availableOfflineCount,omitempty"`
-	AvailableOnlineCount      *bool     `json:"availableOnlineCount,omitempty"`
-	AvailableTempUnknownCount *bool     `json:"availableTempUnknownCount,omitempty"`
-	AvailableUnknownCount     *bool     `json:"availableUnknownCount,omitempty"`
-	BytesTotal                *bool     `json:"bytesTotal,omitempty"`
-	ChildCount                *bool     `json:"childCount,omitempty"`
-	Comment                   *bool     `json:"comment,omitempty"`
-	Enabled                   *bool     `json:"enabled,omitempty"`
-	Hosts                     *bool     `json:"hosts,omitempty"`
-	MaxResults                *int      `json:"maxResults,omitempty"`
-	PackageUUIDs              *[]string `json:"packageUUIDs,omitempty"`
-	Priority                  *bool     `json:"priority,omitempty"`
-	SaveTo                    *bool     `json:"saveTo,omitempty"`
-	StartAt                   *int      `json:"startAt,omitempty"`
-	Status                    *bool     `json:"status,omitempty"`
+	AvailableOfflineCount     *bool    `json:"availableOfflineCount,omitempty"`
+	AvailableOnlineCount      *bool    `json:"availableOnlineCount,omitempty"`
+	AvailableTempUnknownCount *bool    `json:"availableTempUnknownCount,omitempty"`
+	AvailableUnknownCount     *bool    `json:"availableUnknownCount,omitempty"`
+	BytesTotal                *bool    `json:"bytesTotal,omitempty"`
+	ChildCount                *bool    `json:"childCount,omitempty"`
+	Comment                   *bool    `json:"comment,omitempty"`
+	Enabled                   *bool    `json:"enabled,omitempty"`
+	Hosts                     *bool    `json:"hosts,omitempty"`
+	MaxResults                *int     `json:"maxResults,omitempty"`
+	PackageUUIDs              *[]int64 `json:"packageUUIDs,omitempty"`
+	Priority                  *bool    `json:"priority,omitempty"`
+	SaveTo                    *bool    `json:"saveTo,omitempty"`
+	StartAt                   *int     `json:"startAt,omitempty"`
+	Status                    *bool    `json:"status,omitempty"`
 }
 
 type LinkGrabberQueryPackagesOptions func(params *QueryPackagesParams)
 
-func LinkGrabberQueryPackagesOptionPackageUUIDs(uuids []string) LinkGrabberQueryPackagesOptions {
+func LinkGrabberQueryPackagesOptionPackageUUIDs(uuids ...int64) LinkGrabberQueryPackagesOptions {
 	return func(params *QueryPackagesParams) {
 		params.PackageUUIDs = &uuids
 	}
@@ -119,23 +172,38 @@ type CrawledPackage struct {
 	Enabled          *bool     `json:"enabled,omitempty"`
 	Hosts            *[]string `json:"hosts,omitempty"`
 	Name             *string   `json:"name"`
+	Comment          *string   `json:"comment,omitempty"`
+	Priority         *string   `json:"priority,omitempty"`
 }
 
+const availabilityOnline = "ONLINE"
+
+// ContainerType is type of link container file
+type ContainerType string
+
+const (
+	ContainerTypeDLC  = ContainerType("DLC")
+	ContainerTypeCCF  = ContainerType("CCF")
+	ContainerTypeRSDF = ContainerType("RSDF")
+)
+
 type LinkGrabberQueryLinksParams struct {
-	BytesTotal   *bool `json:"bytesTotal,omitempty"`
-	Comment      *bool `json:"comment,omitempty"`
-	Status       *bool `json:"status,omitempty"`
-	Enabled      *bool `json:"enabled,omitempty"`
-	MaxResults   *int  `json:"maxResults,omitempty"`
-	StartAt      *int  `json:"startAt,omitempty"`
-	Hosts        *bool `json:"hosts,omitempty"`
-	Url          *bool `json:"url,omitempty"`
-	Availability *bool `json:"availability,omitempty"`
-	VariantIcon  *bool `json:"variantIcon,omitempty"`
-	VariantName  *bool `json:"variantName,omitempty"`
-	VariantID    *bool `json:"variantID,omitempty"`
-	Variants     *bool `json:"variants,omitempty"`
-	Priority     *bool `json:"priority,omitempty"`
+	BytesTotal   *bool    `json:"bytesTotal,omitempty"`
+	Comment      *bool    `json:"comment,omitempty"`
+	Status       *bool    `json:"status,omitempty"`
+	Enabled      *bool    `json:"enabled,omitempty"`
+	MaxResults   *int     `json:"maxResults,omitempty"`
+	StartAt      *int     `json:"startAt,omitempty"`
+	Hosts        *bool    `json:"hosts,omitempty"`
+	Url          *bool    `json:"url,omitempty"`
+	Availability *bool    `json:"availability,omitempty"`
+	VariantIcon  *bool    `json:"variantIcon,omitempty"`
+	VariantName  *bool    `json:"variantName,omitempty"`
+	VariantID    *bool    `json:"variantID,omitempty"`
+	Variants     *bool    `json:"variants,omitempty"`
+	Priority     *bool    `json:"priority,omitempty"`
+	PackageUUIDs *[]int64 `json:"packageUUIDs,omitempty"`
+	JobUUIDs     *[]int64 `json:"jobUUIDs,omitempty"`
 }
 
 type LinkGrabberQueryLinksOptions func(params *LinkGrabberQueryLinksParams)
@@ -151,42 +219,110 @@ func DefaultLinkGrabberQueryLinksOptions() LinkGrabberQueryLinksOptions {
 		params.Availability = &yes
 		params.VariantIcon = &yes
 		params.VariantID = &yes
+		params.VariantName = &yes
 		params.Variants = &yes
 		params.Priority = &yes
 	}
 }
 
+func LinkGrabberQueryLinksOptionPackageUUIDs(uuids ...int64) LinkGrabberQueryLinksOptions {
+	return func(params *LinkGrabberQueryLinksParams) {
+		params.PackageUUIDs = &uuids
+	}
+}
+
+func LinkGrabberQueryLinksOptionJobUUIDs(uuids ...int64) LinkGrabberQueryLinksOptions {
+	return func(params *LinkGrabberQueryLinksParams) {
+		params.JobUUIDs = &uuids
+	}
+}
+
 type CrawledLink struct {
-	Availability     *string `json:"availability,omitempty"`
-	BytesTotal       *uint64 `json:"bytesTotal,omitempty"`
-	Comment          *string `json:"comment,omitempty"`
-	DownloadPassword *string `json:"downloadPassword,omitempty"`
-	Enabled          *bool   `json:"enabled,omitempty"`
-	Host             *string `json:"host,omitempty"`
-	Name             *string `json:"name,omitempty"`
-	PackageUuid      *int64  `json:"packageUUID,omitempty"`
-	Priority         *string `json:"priority,omitempty"`
-	Url              *string `json:"url,omitempty"`
-	Uuid             *int64  `json:"uuid,omitempty"`
-	Status           *string `json:"status,omitempty"`
-	Variants         *bool   `json:"variants,omitempty"`
+	Availability     *string      `json:"availability,omitempty"`
+	BytesTotal       *uint64      `json:"bytesTotal,omitempty"`
+	Comment          *string      `json:"comment,omitempty"`
+	DownloadPassword *string      `json:"downloadPassword,omitempty"`
+	Enabled          *bool        `json:"enabled,omitempty"`
+	Host             *string      `json:"host,omitempty"`
+	Name             *string      `json:"name,omitempty"`
+	PackageUuid      *int64       `json:"packageUUID,omitempty"`
+	Priority         *string      `json:"priority,omitempty"`
+	Url              *string      `json:"url,omitempty"`
+	Uuid             *int64       `json:"uuid,omitempty"`
+	Status           *string      `json:"status,omitempty"`
+	Variants         *bool        `json:"variants,omitempty"`
+	Variant          *LinkVariant `json:"variant,omitempty"`
+}
+
+// LinkVariant is alternative form of link offered by hoster, such as video resolution or format
+type LinkVariant struct {
+	Id      *string `json:"id,omitempty"`
+	Name    *string `json:"name,omitempty"`
+	IconKey *string `json:"iconKey,omitempty"`
 }
 
 type LinkGrabber interface {
 	// Clear clears list of links
-	Clear() error
+	Clear(context.Context) error
 	// Packages gets list of packages
-	Packages(options ...LinkGrabberQueryPackagesOptions) (*[]CrawledPackage, error)
+	Packages(ctx context.Context, options ...LinkGrabberQueryPackagesOptions) (*[]CrawledPackage, error)
 	// Links queries links currently being present
-	Links(...LinkGrabberQueryLinksOptions) (*[]CrawledLink, error)
+	Links(context.Context, ...LinkGrabberQueryLinksOptions) (*[]CrawledLink, error)
 	// Add adds one or more links into download queue
-	Add([]string, ...AddLinksOptions) (*DataResponse, error)
+	Add(context.Context, []string, ...AddLinksOptions) (*LinkCollectingJob, error)
+	// AddContainer adds links from content of container file.
+	// JDownloader ignores add options for containers, so package name, destination folder, download password
+	// and autostart are applied to packages of the job once it's finished, which blocks until then.
+	// Extract password can't be set afterwards and is rejected.
+	AddContainer(context.Context, ContainerType, []byte, ...AddLinksOptions) (*LinkCollectingJob, error)
+	// AddAndWait adds links, waits until their crawler job is finished and returns links and packages it produced
+	AddAndWait(context.Context, []string, ...AddLinksOptions) (*LinkCollectingResult, error)
+	// CrawlerJobs gets state of given crawler jobs
+	CrawlerJobs(ctx context.Context, jobIds ...int64) ([]CrawlerJob, error)
 	// IsCollecting checks if link grabber is collecting links
-	IsCollecting() (bool, error)
+	IsCollecting(context.Context) (bool, error)
 	// Remove removes given linksIds and/or packageIds
-	Remove([]int64, []int64) error
+	Remove(context.Context, []int64, []int64) error
 	// RenameLink renames link
-	RenameLink(int64, string) error
+	RenameLink(context.Context, int64, string) error
+	// Cleanup removes links matching action from selection
+	Cleanup(ctx context.Context, linkIds []int64, packageIds []int64, action CleanupAction, mode CleanupMode, selection CleanupSelection) error
+	// RenamePackage renames package
+	RenamePackage(context.Context, int64, string) error
+	// SetDownloadDirectory changes download directory of given packages
+	SetDownloadDirectory(ctx context.Context, directory string, packageIds []int64) error
+	// SetPriority sets priority of given links/packages
+	SetPriority(ctx context.Context, priority Priority, linkIds []int64, packageIds []int64) error
+	// SetEnabled enables or disables given links/packages
+	SetEnabled(ctx context.Context, enabled bool, linkIds []int64, packageIds []int64) error
+	// MovePackages moves given packages after package with given UUID
+	MovePackages(ctx context.Context, packageIds []int64, afterPackageId int64) error
+	// MoveLinks moves given links into destination package, after link with given UUID
+	MoveLinks(ctx context.Context, linkIds []int64, afterLinkId int64, destPackageId int64) error
+	// MoveToNewPackage moves given links/packages into newly created package
+	MoveToNewPackage(ctx context.Context, linkIds []int64, packageIds []int64, name string, downloadPath string) error
+	// SplitPackageByHoster splits given links/packages into new packages, one per hoster
+	SplitPackageByHoster(ctx context.Context, linkIds []int64, packageIds []int64) error
+	// SetDownloadPassword sets download password of given links/packages
+	SetDownloadPassword(ctx context.Context, linkIds []int64, packageIds []int64, password string) error
+	// SetComment sets comment of given links/packages. When setPackageChildren is true,
+	// comment is also set on all links of given packages.
+	SetComment(ctx context.Context, linkIds []int64, packageIds []int64, setPackageChildren bool, comment string) error
+	// StartOnlineStatusCheck starts checking availability of given links/packages
+	StartOnlineStatusCheck(ctx context.Context, linkIds []int64, packageIds []int64) error
+	// Variants gets variants available for link
+	Variants(ctx context.Context, linkId int64) ([]LinkVariant, error)
+	// SetVariant selects variant of link
+	SetVariant(ctx context.Context, linkId int64, variantId string) error
+	// AddVariantCopy adds copy of link with given variant into destination package, after link with given UUID
+	AddVariantCopy(ctx context.Context, linkId int64, afterLinkId int64, destPackageId int64, variantId string) error
+	// MoveToDownloadList confirms given links and/or packages, moving them into download list
+	MoveToDownloadList(ctx context.Context, linkIds []int64, packageIds []int64) error
+	// ConfirmAllOnline moves all online links into download list and returns their UUIDs
+	ConfirmAllOnline(context.Context) ([]int64, error)
+	// ChildrenChanged gets counter of structural changes of link grabber list.
+	// It returns -1 if counter still equals to given value.
+	ChildrenChanged(ctx context.Context, old int64) (int64, error)
 }
 
 type linkGrabber struct {
@@ -201,7 +337,7 @@ func newLinkGrabber(log *slog.Logger, device *jDevice) LinkGrabber {
 	}
 }
 
-func (l *linkGrabber) Links(options ...LinkGrabberQueryLinksOptions) (*[]CrawledLink, error) {
+func (l *linkGrabber) Links(ctx context.Context, options ...LinkGrabberQueryLinksOptions) (*[]CrawledLink, error) {
 	params := &LinkGrabberQueryLinksParams{}
 	if len(options) == 0 {
 		defaults := DefaultLinkGrabberQueryLinksOptions()
@@ -210,7 +346,7 @@ func (l *linkGrabber) Links(options ...LinkGrabberQueryLinksOptions) (*[]Crawled
 	for _, opt := range options {
 		opt(params)
 	}
-	data, err := l.d.doDevice("/linkgrabberv2/queryLinks", true, params)
+	data, err := l.d.doDevice(ctx, "/linkgrabberv2/queryLinks", true, params)
 	if err != nil {
 		return nil, err
 	}
@@ -222,31 +358,73 @@ func (l *linkGrabber) Links(options ...LinkGrabberQueryLinksOptions) (*[]Crawled
 	return &items, nil
 }
 
-func (l *linkGrabber) Packages(options ...LinkGrabberQueryPackagesOptions) (*[]CrawledPackage, error) {
-	return queryPackages("linkgrabberv2", l.d, options...)
+func (l *linkGrabber) Packages(ctx context.Context, options ...LinkGrabberQueryPackagesOptions) (*[]CrawledPackage, error) {
+	return queryPackages(ctx, "linkgrabberv2", l.d, options...)
 }
 
-func (l *linkGrabber) Add(links []string, options ...AddLinksOptions) (*DataResponse, error) {
+func (l *linkGrabber) Add(ctx context.Context, links []string, options ...AddLinksOptions) (*LinkCollectingJob, error) {
 	params := &AddLinksParams{
 		Links: strings.Join(links, ","),
 	}
 	for _, opt := range options {
 		opt(params)
 	}
-	data, err := l.d.doDevice("/linkgrabberv2/addLinks", true, params)
+	data, err := l.d.doDevice(ctx, "/linkgrabberv2/addLinks", true, params)
+	if err != nil {
+		return nil, err
+	}
+	job := &LinkCollectingJob{}
+	err = toObj(data, job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (l *linkGrabber) AddContainer(ctx context.Context, containerType ContainerType, content []byte, options ...AddLinksOptions) (*LinkCollectingJob, error) {
+	if len(content) == 0 {
+		return nil, errors.New("container content must not be empty")
+	}
+	params := &AddLinksParams{}
+	for _, opt := range options {
+		opt(params)
+	}
+	if params.ExtractPassword != nil {
+		return nil, errors.New("extract password is not supported for containers")
+	}
+	dataUrl := "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(content)
+	data, err := l.d.doDevice(ctx, "/linkgrabberv2/addContainer", false, containerType, dataUrl)
+	if err != nil {
+		return nil, err
+	}
+	job := &LinkCollectingJob{}
+	err = toObj(data, job)
+	if err != nil {
+		return nil, err
+	}
+	return job, applyAddLinksParams(ctx, l, *job, params)
+}
+
+func (l *linkGrabber) CrawlerJobs(ctx context.Context, jobIds ...int64) ([]CrawlerJob, error) {
+	params := &LinkCrawlerJobsQueryParams{CollectorInfo: &yes, JobIds: &jobIds}
+	data, err := l.d.doDevice(ctx, "/linkgrabberv2/queryLinkCrawlerJobs", true, params)
 	if err != nil {
 		return nil, err
 	}
-	resp := &DataResponse{}
-	err = toObj(data, resp)
+	jobs := make([]CrawlerJob, 0)
+	err = toObj(data, &jobs)
 	if err != nil {
 		return nil, err
 	}
-	return resp, nil
+	return jobs, nil
+}
+
+func (l *linkGrabber) AddAndWait(ctx context.Context, links []string, options ...AddLinksOptions) (*LinkCollectingResult, error) {
+	return addAndWait(ctx, l, links, options...)
 }
 
-func (l *linkGrabber) IsCollecting() (bool, error) {
-	data, err := l.d.doDevice("/linkgrabberv2/isCollecting", false, nil)
+func (l *linkGrabber) IsCollecting(ctx context.Context) (bool, error) {
+	data, err := l.d.doDevice(ctx, "/linkgrabberv2/isCollecting", false, nil)
 	if err != nil {
 		return false, err
 	}
@@ -255,25 +433,269 @@ func (l *linkGrabber) IsCollecting() (bool, error) {
 	return res, err
 }
 
-func (l *linkGrabber) Remove(linkIds []int64, packageIds []int64) error {
+func (l *linkGrabber) Remove(ctx context.Context, linkIds []int64, packageIds []int64) error {
 	if len(linkIds) == 0 && len(packageIds) == 0 {
 		return errors.New("One of linkIds or packageIds must not be empty")
 	}
-	_, err := l.d.doDevice("/linkgrabberv2/removeLinks", false, linkIds, packageIds)
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/removeLinks", false, uuids(linkIds), uuids(packageIds))
 	return err
 }
 
-func (l *linkGrabber) Clear() error {
-	_, err := l.d.doDevice("/linkgrabberv2/clearList", false)
+func (l *linkGrabber) Clear(ctx context.Context) error {
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/clearList", false)
 	return err
 }
 
-func (l *linkGrabber) RenameLink(id int64, name string) error {
-	_, err := l.d.doDevice("/linkgrabberv2/renameLink", false, id, name)
+func (l *linkGrabber) RenameLink(ctx context.Context, id int64, name string) error {
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/renameLink", false, id, name)
+	return err
+}
+
+func (l *linkGrabber) Cleanup(ctx context.Context, linkIds []int64, packageIds []int64,
+	action CleanupAction, mode CleanupMode, selection CleanupSelection) error {
+	return cleanup(ctx, "linkgrabberv2", l.d, linkIds, packageIds, action, mode, selection)
+}
+
+func (l *linkGrabber) RenamePackage(ctx context.Context, id int64, name string) error {
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/renamePackage", false, id, name)
 	return err
 }
 
-func queryPackages(prefix string, d *jDevice, options ...LinkGrabberQueryPackagesOptions) (*[]CrawledPackage, error) {
+func (l *linkGrabber) SetDownloadDirectory(ctx context.Context, directory string, packageIds []int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/setDownloadDirectory", false, directory, uuids(packageIds))
+	return err
+}
+
+func (l *linkGrabber) SetPriority(ctx context.Context, priority Priority, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/setPriority", false, priority, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (l *linkGrabber) SetEnabled(ctx context.Context, enabled bool, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/setEnabled", false, enabled, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (l *linkGrabber) MovePackages(ctx context.Context, packageIds []int64, afterPackageId int64) error {
+	if len(packageIds) == 0 {
+		return errors.New("packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/movePackages", false, uuids(packageIds), afterPackageId)
+	return err
+}
+
+func (l *linkGrabber) MoveLinks(ctx context.Context, linkIds []int64, afterLinkId int64, destPackageId int64) error {
+	if len(linkIds) == 0 {
+		return errors.New("linkIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/moveLinks", false, uuids(linkIds), afterLinkId, destPackageId)
+	return err
+}
+
+func (l *linkGrabber) MoveToNewPackage(ctx context.Context, linkIds []int64, packageIds []int64, name string, downloadPath string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/movetoNewPackage", false, uuids(linkIds), uuids(packageIds), name, downloadPath)
+	return err
+}
+
+func (l *linkGrabber) SplitPackageByHoster(ctx context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/splitPackageByHoster", false, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (l *linkGrabber) SetDownloadPassword(ctx context.Context, linkIds []int64, packageIds []int64, password string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/setDownloadPassword", false, uuids(linkIds), uuids(packageIds), password)
+	return err
+}
+
+func (l *linkGrabber) SetComment(ctx context.Context, linkIds []int64, packageIds []int64, setPackageChildren bool, comment string) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/setComment", false, uuids(linkIds), uuids(packageIds), setPackageChildren, comment)
+	return err
+}
+
+func (l *linkGrabber) StartOnlineStatusCheck(ctx context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/startOnlineStatusCheck", false, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (l *linkGrabber) Variants(ctx context.Context, linkId int64) ([]LinkVariant, error) {
+	data, err := l.d.doDevice(ctx, "/linkgrabberv2/getVariants", false, linkId)
+	if err != nil {
+		return nil, err
+	}
+	variants := make([]LinkVariant, 0)
+	err = toObj(data, &variants)
+	if err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (l *linkGrabber) SetVariant(ctx context.Context, linkId int64, variantId string) error {
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/setVariant", false, linkId, variantId)
+	return err
+}
+
+func (l *linkGrabber) AddVariantCopy(ctx context.Context, linkId int64, afterLinkId int64, destPackageId int64, variantId string) error {
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/addVariantCopy", false, linkId, afterLinkId, destPackageId, variantId)
+	return err
+}
+
+func (l *linkGrabber) MoveToDownloadList(ctx context.Context, linkIds []int64, packageIds []int64) error {
+	if len(linkIds) == 0 && len(packageIds) == 0 {
+		return errors.New("one of linkIds or packageIds must not be empty")
+	}
+	_, err := l.d.doDevice(ctx, "/linkgrabberv2/moveToDownloadlist", false, uuids(linkIds), uuids(packageIds))
+	return err
+}
+
+func (l *linkGrabber) ConfirmAllOnline(ctx context.Context) ([]int64, error) {
+	online := make([]int64, 0)
+	for link, err := range CrawledLinks(ctx, l, DefaultPageSize) {
+		if err != nil {
+			return nil, err
+		}
+		if link.Uuid != nil && link.Availability != nil && *link.Availability == availabilityOnline {
+			online = append(online, *link.Uuid)
+		}
+	}
+	if len(online) == 0 {
+		return online, nil
+	}
+	return online, l.MoveToDownloadList(ctx, online, []int64{})
+}
+
+func (l *linkGrabber) ChildrenChanged(ctx context.Context, old int64) (int64, error) {
+	return changeCounter(ctx, l.d, "/linkgrabberv2/getChildrenChanged", old)
+}
+
+// addAndWait adds links with job id assigned, waits until its crawler job is done and collects its results
+func addAndWait(ctx context.Context, lg LinkGrabber, links []string, options ...AddLinksOptions) (*LinkCollectingResult, error) {
+	job, err := lg.Add(ctx, links, append(slices.Clip(options), AddLinksOptionAssignJobID(true))...)
+	if err != nil {
+		return nil, err
+	}
+	return waitForJob(ctx, lg, *job)
+}
+
+// waitForJob polls crawler job until it's done and collects links and packages it produced
+func waitForJob(ctx context.Context, lg LinkGrabber, job LinkCollectingJob) (*LinkCollectingResult, error) {
+	// job may not be listed yet right after it's added, and finished jobs may be dropped from the list,
+	// so it's done only once it was observed working and is now idle or gone
+	seen := false
+	for {
+		jobs, err := lg.CrawlerJobs(ctx, job.Id)
+		if err != nil {
+			return nil, err
+		}
+		idx := slices.IndexFunc(jobs, func(j CrawlerJob) bool {
+			return j.JobId != nil && *j.JobId == job.Id
+		})
+		if idx == -1 {
+			if seen {
+				break
+			}
+		} else {
+			j := jobs[idx]
+			if isTrue(j.Crawling) || isTrue(j.Checking) {
+				seen = true
+			} else if seen || j.progressed() {
+				break
+			}
+		}
+		if !sleepCtx(ctx, crawlerJobPollInterval) {
+			return nil, ctx.Err()
+		}
+	}
+	result := &LinkCollectingResult{Job: job, Links: make([]CrawledLink, 0), Packages: make([]CrawledPackage, 0)}
+	pkgIds := make([]int64, 0)
+	for link, err := range CrawledLinks(ctx, lg, DefaultPageSize, DefaultLinkGrabberQueryLinksOptions(),
+		LinkGrabberQueryLinksOptionJobUUIDs(job.Id)) {
+		if err != nil {
+			return nil, err
+		}
+		result.Links = append(result.Links, link)
+		if link.PackageUuid != nil && !slices.Contains(pkgIds, *link.PackageUuid) {
+			pkgIds = append(pkgIds, *link.PackageUuid)
+		}
+	}
+	if len(pkgIds) == 0 {
+		return result, nil
+	}
+	pkgs, err := lg.Packages(ctx, QueryPackagesOptionDefault(), LinkGrabberQueryPackagesOptionPackageUUIDs(pkgIds...))
+	if err != nil {
+		return nil, err
+	}
+	result.Packages = *pkgs
+	return result, nil
+}
+
+// applyAddLinksParams applies add options to packages produced by finished job, for cases where
+// JDownloader doesn't accept them upfront (containers)
+func applyAddLinksParams(ctx context.Context, lg LinkGrabber, job LinkCollectingJob, params *AddLinksParams) error {
+	if params.PackageName == nil && params.DestinationFolder == nil && params.DownloadPassword == nil && !isTrue(params.Autostart) {
+		return nil
+	}
+	result, err := waitForJob(ctx, lg, job)
+	if err != nil {
+		return err
+	}
+	pkgIds := make([]int64, 0, len(result.Packages))
+	for _, pkg := range result.Packages {
+		if pkg.Uuid != nil {
+			pkgIds = append(pkgIds, *pkg.Uuid)
+		}
+	}
+	if len(pkgIds) == 0 {
+		return nil
+	}
+	if params.PackageName != nil {
+		for _, id := range pkgIds {
+			if err = lg.RenamePackage(ctx, id, *params.PackageName); err != nil {
+				return err
+			}
+		}
+	}
+	if params.DestinationFolder != nil {
+		if err = lg.SetDownloadDirectory(ctx, *params.DestinationFolder, pkgIds); err != nil {
+			return err
+		}
+	}
+	if params.DownloadPassword != nil {
+		if err = lg.SetDownloadPassword(ctx, nil, pkgIds, *params.DownloadPassword); err != nil {
+			return err
+		}
+	}
+	if isTrue(params.Autostart) {
+		return lg.MoveToDownloadList(ctx, nil, pkgIds)
+	}
+	return nil
+}
+
+func queryPackages(ctx context.Context, prefix string, d *jDevice, options ...LinkGrabberQueryPackagesOptions) (*[]CrawledPackage, error) {
 	params := &QueryPackagesParams{}
 	if len(options) == 0 {
 		options = append(options, QueryPackagesOptionDefault())
@@ -281,7 +703,7 @@ func queryPackages(prefix string, d *jDevice, options ...LinkGrabberQueryPackage
 	for _, opt := range options {
 		opt(params)
 	}
-	data, err := d.doDevice(fmt.Sprintf("/%s/queryPackages", prefix), true, params)
+	data, err := d.doDevice(ctx, fmt.Sprintf("/%s/queryPackages", prefix), true, params)
 	if err != nil {
 		return nil, err
 	}
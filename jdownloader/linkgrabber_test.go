@@ -0,0 +1,254 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmAllOnline(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	d.Respond("/linkgrabberv2/queryLinks", []jdownloader.CrawledLink{
+		{Uuid: ptr(int64(1)), Availability: ptr("ONLINE")},
+		{Uuid: ptr(int64(2)), Availability: ptr("OFFLINE")},
+		{Uuid: ptr(int64(3)), Availability: ptr("ONLINE")},
+	})
+	d.Handle("/linkgrabberv2/moveToDownloadlist", func(params []json.RawMessage) (interface{}, error) {
+		var linkIds, packageIds []int64
+		assert.NoError(t, jdtest.DecodeParam(params[0], &linkIds))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &packageIds))
+		assert.Equal(t, []int64{1, 3}, linkIds)
+		assert.Empty(t, packageIds)
+		return nil, nil
+	})
+	confirmed, err := dev.LinkGrabber().ConfirmAllOnline(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 3}, confirmed)
+	assert.Equal(t, 1, s.Calls("/linkgrabberv2/moveToDownloadlist"))
+	assert.Error(t, dev.LinkGrabber().MoveToDownloadList(t.Context(), nil, nil))
+}
+
+func TestAddContainer(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	d.Handle("/linkgrabberv2/addContainer", func(params []json.RawMessage) (interface{}, error) {
+		var containerType, content string
+		assert.NoError(t, jdtest.DecodeParam(params[0], &containerType))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &content))
+		assert.Equal(t, "DLC", containerType)
+		assert.Equal(t, "data:application/octet-stream;base64,"+base64.StdEncoding.EncodeToString([]byte("dlc-content")), content)
+		return map[string]interface{}{"id": 42}, nil
+	})
+	_, err := dev.LinkGrabber().AddContainer(t.Context(), jdownloader.ContainerTypeDLC, []byte("dlc-content"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s.Calls("/linkgrabberv2/addContainer"))
+	_, err = dev.LinkGrabber().AddContainer(t.Context(), jdownloader.ContainerTypeDLC, nil)
+	assert.Error(t, err)
+}
+
+func TestAddContainerOptions(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	d.Respond("/linkgrabberv2/addContainer", map[string]interface{}{"id": 42})
+	d.Respond("/linkgrabberv2/queryLinkCrawlerJobs", []jdownloader.CrawlerJob{{JobId: ptr(int64(42)), Crawling: ptr(false), Checking: ptr(false), Crawled: ptr(1)}})
+	d.Respond("/linkgrabberv2/queryLinks", []jdownloader.CrawledLink{{Uuid: ptr(int64(1)), PackageUuid: ptr(int64(10))}})
+	d.Respond("/linkgrabberv2/queryPackages", []jdownloader.CrawledPackage{{Uuid: ptr(int64(10))}})
+	d.Handle("/linkgrabberv2/renamePackage", func(params []json.RawMessage) (interface{}, error) {
+		var id int64
+		var name string
+		assert.NoError(t, jdtest.DecodeParam(params[0], &id))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &name))
+		assert.Equal(t, int64(10), id)
+		assert.Equal(t, "pkg", name)
+		return nil, nil
+	})
+	d.Handle("/linkgrabberv2/setDownloadDirectory", func(params []json.RawMessage) (interface{}, error) {
+		var dir string
+		var packageIds []int64
+		assert.NoError(t, jdtest.DecodeParam(params[0], &dir))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &packageIds))
+		assert.Equal(t, "/mnt", dir)
+		assert.Equal(t, []int64{10}, packageIds)
+		return nil, nil
+	})
+	d.Handle("/linkgrabberv2/setDownloadPassword", func(params []json.RawMessage) (interface{}, error) {
+		var packageIds []int64
+		var password string
+		assert.NoError(t, jdtest.DecodeParam(params[1], &packageIds))
+		assert.NoError(t, jdtest.DecodeParam(params[2], &password))
+		assert.Equal(t, []int64{10}, packageIds)
+		assert.Equal(t, "secret", password)
+		return nil, nil
+	})
+	lg := dev.LinkGrabber()
+	job, err := lg.AddContainer(t.Context(), jdownloader.ContainerTypeDLC, []byte("dlc-content"),
+		jdownloader.AddLinksOptionPackage("pkg"), jdownloader.AddLinksOptionDestinationDir("/mnt"),
+		jdownloader.AddLinksOptionDownloadPassword("secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), job.Id)
+	assert.Equal(t, 1, s.Calls("/linkgrabberv2/renamePackage"))
+	assert.Equal(t, 1, s.Calls("/linkgrabberv2/setDownloadDirectory"))
+	assert.Equal(t, 1, s.Calls("/linkgrabberv2/setDownloadPassword"))
+
+	_, err = lg.AddContainer(t.Context(), jdownloader.ContainerTypeDLC, []byte("dlc-content"),
+		jdownloader.AddLinksOptionExtractPassword("secret"))
+	assert.Error(t, err)
+	assert.Equal(t, 1, s.Calls("/linkgrabberv2/addContainer"))
+}
+
+func TestVariants(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	d.Respond("/linkgrabberv2/getVariants", []map[string]string{
+		{"id": "MP4_360", "name": "360p MP4", "iconKey": "video"},
+		{"id": "MP4_1080", "name": "1080p MP4", "iconKey": "video"},
+	})
+	d.Handle("/linkgrabberv2/queryLinks", func(params []json.RawMessage) (interface{}, error) {
+		query := &jdownloader.LinkGrabberQueryLinksParams{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], query))
+		assert.True(t, *query.VariantName)
+		return []map[string]interface{}{
+			{"uuid": 1, "variants": true, "variant": map[string]string{"id": "MP4_1080", "name": "1080p MP4"}},
+		}, nil
+	})
+	d.Handle("/linkgrabberv2/addVariantCopy", func(params []json.RawMessage) (interface{}, error) {
+		var linkId, after, dest int64
+		var variant string
+		assert.NoError(t, jdtest.DecodeParam(params[0], &linkId))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &after))
+		assert.NoError(t, jdtest.DecodeParam(params[2], &dest))
+		assert.NoError(t, jdtest.DecodeParam(params[3], &variant))
+		assert.Equal(t, []interface{}{int64(1), int64(1), int64(10), "MP4_360"}, []interface{}{linkId, after, dest, variant})
+		return nil, nil
+	})
+	d.Respond("/linkgrabberv2/setVariant", nil)
+	lg := dev.LinkGrabber()
+
+	variants, err := lg.Variants(t.Context(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, variants, 2)
+	assert.Equal(t, "1080p MP4", *variants[1].Name)
+	assert.NoError(t, lg.SetVariant(t.Context(), 1, *variants[1].Id))
+	assert.NoError(t, lg.AddVariantCopy(t.Context(), 1, 1, 10, *variants[0].Id))
+	links, err := lg.Links(t.Context(), jdownloader.DefaultLinkGrabberQueryLinksOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "MP4_1080", *(*links)[0].Variant.Id)
+	assert.Equal(t, "1080p MP4", *(*links)[0].Variant.Name)
+}
+
+func TestLinkGrabberPackageManagement(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	d.Handle("/linkgrabberv2/movetoNewPackage", func(params []json.RawMessage) (interface{}, error) {
+		var linkIds, packageIds []int64
+		var name, path string
+		assert.NoError(t, jdtest.DecodeParam(params[0], &linkIds))
+		assert.NoError(t, jdtest.DecodeParam(params[1], &packageIds))
+		assert.NoError(t, jdtest.DecodeParam(params[2], &name))
+		assert.NoError(t, jdtest.DecodeParam(params[3], &path))
+		assert.Equal(t, []int64{1, 2}, linkIds)
+		assert.Equal(t, "videos", name)
+		assert.Equal(t, "/mnt/videos", path)
+		return nil, nil
+	})
+	d.Handle("/linkgrabberv2/setComment", func(params []json.RawMessage) (interface{}, error) {
+		var children bool
+		var comment string
+		assert.NoError(t, jdtest.DecodeParam(params[2], &children))
+		assert.NoError(t, jdtest.DecodeParam(params[3], &comment))
+		assert.True(t, children)
+		assert.Equal(t, "reviewed", comment)
+		return nil, nil
+	})
+	d.Respond("/linkgrabberv2/startOnlineStatusCheck", nil)
+	lg := dev.LinkGrabber()
+	assert.NoError(t, lg.MoveToNewPackage(t.Context(), []int64{1, 2}, nil, "videos", "/mnt/videos"))
+	assert.NoError(t, lg.SetComment(t.Context(), nil, []int64{10}, true, "reviewed"))
+	assert.NoError(t, lg.StartOnlineStatusCheck(t.Context(), nil, []int64{10}))
+	assert.Error(t, lg.SetPriority(t.Context(), jdownloader.PriorityHigh, nil, nil))
+}
+
+func TestAddAndWait(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	d.Handle("/linkgrabberv2/addLinks", func(params []json.RawMessage) (interface{}, error) {
+		add := &jdownloader.AddLinksParams{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], add))
+		assert.True(t, *add.AssignJobID)
+		assert.Equal(t, "pkg", *add.PackageName)
+		return map[string]interface{}{"id": 7}, nil
+	})
+	var polls atomic.Int32
+	d.Handle("/linkgrabberv2/queryLinkCrawlerJobs", func(params []json.RawMessage) (interface{}, error) {
+		query := &jdownloader.LinkCrawlerJobsQueryParams{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], query))
+		assert.Equal(t, []int64{7}, *query.JobIds)
+		crawling := polls.Add(1) == 1
+		return []jdownloader.CrawlerJob{{JobId: ptr(int64(7)), Crawling: &crawling, Checking: ptr(false)}}, nil
+	})
+	d.Handle("/linkgrabberv2/queryLinks", func(params []json.RawMessage) (interface{}, error) {
+		query := &jdownloader.LinkGrabberQueryLinksParams{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], query))
+		assert.Equal(t, []int64{7}, *query.JobUUIDs)
+		return []jdownloader.CrawledLink{{Uuid: ptr(int64(1)), PackageUuid: ptr(int64(10))}}, nil
+	})
+	d.Handle("/linkgrabberv2/queryPackages", func(params []json.RawMessage) (interface{}, error) {
+		query := &jdownloader.QueryPackagesParams{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], query))
+		assert.Equal(t, []int64{10}, *query.PackageUUIDs)
+		return []jdownloader.CrawledPackage{{Uuid: ptr(int64(10)), Name: ptr("pkg")}}, nil
+	})
+
+	res, err := dev.LinkGrabber().AddAndWait(t.Context(), []string{"https://a.tld/1"}, jdownloader.AddLinksOptionPackage("pkg"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), res.Job.Id)
+	assert.Equal(t, int32(2), polls.Load())
+	assert.Len(t, res.Links, 1)
+	assert.Equal(t, "pkg", *res.Packages[0].Name)
+}
+
+func TestAddAndWaitJobNotListedYet(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	d.Respond("/linkgrabberv2/addLinks", map[string]interface{}{"id": 7})
+	var polls atomic.Int32
+	d.Handle("/linkgrabberv2/queryLinkCrawlerJobs", func([]json.RawMessage) (interface{}, error) {
+		switch polls.Add(1) {
+		case 1:
+			return []jdownloader.CrawlerJob{}, nil
+		case 2:
+			return []jdownloader.CrawlerJob{{JobId: ptr(int64(7)), Crawling: ptr(false), Checking: ptr(false)}}, nil
+		default:
+			return []jdownloader.CrawlerJob{{JobId: ptr(int64(7)), Crawling: ptr(false), Checking: ptr(false), Crawled: ptr(1)}}, nil
+		}
+	})
+	d.Respond("/linkgrabberv2/queryLinks", []jdownloader.CrawledLink{{Uuid: ptr(int64(1))}})
+
+	res, err := dev.LinkGrabber().AddAndWait(t.Context(), []string{"https://a.tld/1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), polls.Load())
+	assert.Len(t, res.Links, 1)
+
+	d.Respond("/linkgrabberv2/queryLinkCrawlerJobs", []jdownloader.CrawlerJob{})
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	_, err = dev.LinkGrabber().AddAndWait(ctx, []string{"https://a.tld/1"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"iter"
+	"slices"
+)
+
+// DefaultPageSize is number of items fetched in single request by iterators, when page size is not positive
+const DefaultPageSize = 500
+
+// DownloadLinks iterates over all links in download list, fetching them in pages of given size.
+// Iteration stops after first error.
+func DownloadLinks(ctx context.Context, dl Downloader, pageSize int, options ...DownloadQueryLinksOptions) iter.Seq2[DownloadLink, error] {
+	if len(options) == 0 {
+		options = append(options, DefaultDownloadQueryLinksOptions())
+	}
+	return paginate(pageSize, func(startAt, maxResults int) (*[]DownloadLink, error) {
+		return dl.Links(ctx, append(slices.Clip(options), func(params *DownloadQueryLinksParams) {
+			params.StartAt = &startAt
+			params.MaxResults = &maxResults
+		})...)
+	})
+}
+
+// DownloadPackages iterates over all packages in download list, fetching them in pages of given size.
+// Iteration stops after first error.
+func DownloadPackages(ctx context.Context, dl Downloader, pageSize int, options ...DownloadQueryPackagesOptions) iter.Seq2[DownloadPackage, error] {
+	if len(options) == 0 {
+		options = append(options, DefaultDownloadQueryPackagesOptions())
+	}
+	return paginate(pageSize, func(startAt, maxResults int) (*[]DownloadPackage, error) {
+		return dl.Packages(ctx, append(slices.Clip(options),
+			DownloadQueryPackagesOptionStartAt(startAt), DownloadQueryPackagesOptionMaxResults(maxResults))...)
+	})
+}
+
+// CrawledLinks iterates over all links in link grabber, fetching them in pages of given size.
+// Iteration stops after first error.
+func CrawledLinks(ctx context.Context, lg LinkGrabber, pageSize int, options ...LinkGrabberQueryLinksOptions) iter.Seq2[CrawledLink, error] {
+	if len(options) == 0 {
+		options = append(options, DefaultLinkGrabberQueryLinksOptions())
+	}
+	return paginate(pageSize, func(startAt, maxResults int) (*[]CrawledLink, error) {
+		return lg.Links(ctx, append(slices.Clip(options), func(params *LinkGrabberQueryLinksParams) {
+			params.StartAt = &startAt
+			params.MaxResults = &maxResults
+		})...)
+	})
+}
+
+// CrawledPackages iterates over all packages in link grabber, fetching them in pages of given size.
+// Iteration stops after first error.
+func CrawledPackages(ctx context.Context, lg LinkGrabber, pageSize int, options ...LinkGrabberQueryPackagesOptions) iter.Seq2[CrawledPackage, error] {
+	if len(options) == 0 {
+		options = append(options, QueryPackagesOptionDefault())
+	}
+	return paginate(pageSize, func(startAt, maxResults int) (*[]CrawledPackage, error) {
+		return lg.Packages(ctx, append(slices.Clip(options), func(params *QueryPackagesParams) {
+			params.StartAt = &startAt
+			params.MaxResults = &maxResults
+		})...)
+	})
+}
+
+// paginate calls fetch with increasing offset until it returns page shorter than page size
+func paginate[T any](pageSize int, fetch func(startAt, maxResults int) (*[]T, error)) iter.Seq2[T, error] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return func(yield func(T, error) bool) {
+		for startAt := 0; ; startAt += pageSize {
+			items, err := fetch(startAt, pageSize)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range *items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if len(*items) < pageSize {
+				return
+			}
+		}
+	}
+}
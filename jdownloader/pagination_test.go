@@ -0,0 +1,76 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadLinksPagination(t *testing.T) {
+	s, d, dev := newTestDevice(t)
+	d.Handle("/downloadsV2/queryLinks", func(params []json.RawMessage) (interface{}, error) {
+		query := &jdownloader.DownloadQueryLinksParams{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], query))
+		assert.True(t, *query.BytesLoaded, "default options should be used")
+		res := make([]jdownloader.DownloadLink, 0)
+		for i := *query.StartAt; i < min(*query.StartAt+*query.MaxResults, 5); i++ {
+			res = append(res, jdownloader.DownloadLink{Uuid: ptr(int64(i))})
+		}
+		return res, nil
+	})
+
+	ids := make([]int64, 0)
+	for l, err := range jdownloader.DownloadLinks(t.Context(), dev.Downloader(), 2) {
+		assert.NoError(t, err)
+		ids = append(ids, *l.Uuid)
+	}
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, ids)
+	assert.Equal(t, 3, s.Calls("/downloadsV2/queryLinks"))
+
+	for l := range jdownloader.DownloadLinks(t.Context(), dev.Downloader(), 2) {
+		if *l.Uuid == 2 {
+			break
+		}
+	}
+	assert.Equal(t, 5, s.Calls("/downloadsV2/queryLinks"), "iteration should stop when consumer breaks")
+
+	d.Inject("/downloadsV2/queryLinks", 1, 400, jdownloader.ErrorTypeBadParameters)
+	for _, err := range jdownloader.DownloadLinks(t.Context(), dev.Downloader(), 2) {
+		assert.Error(t, err)
+	}
+}
+
+func TestMockCrawledPagination(t *testing.T) {
+	dev := newMockDevice(t)
+	_, err := dev.LinkGrabber().Add(t.Context(), []string{"https://a.tld/1", "https://a.tld/2", "https://a.tld/3"})
+	assert.NoError(t, err)
+	var count int
+	for _, err := range jdownloader.CrawledLinks(t.Context(), dev.LinkGrabber(), 2) {
+		assert.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 3, count)
+	for p, err := range jdownloader.CrawledPackages(t.Context(), dev.LinkGrabber(), 0) {
+		assert.NoError(t, err)
+		assert.Equal(t, 3, *p.ChildCount)
+	}
+}
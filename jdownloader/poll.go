@@ -0,0 +1,130 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const (
+	pollJdState           = "jdState"
+	pollLinkGrabberState  = "linkGrabberState"
+	pollAggregatedNumbers = "aggregatedNumbers"
+)
+
+type PollParams struct {
+	AggregatedNumbers *bool `json:"aggregatedNumbers,omitempty"`
+	JdState           *bool `json:"jdState,omitempty"`
+	LinkGrabberState  *bool `json:"linkGrabberState,omitempty"`
+}
+
+type PollOptions func(params *PollParams)
+
+func PollOptionAggregatedNumbers() PollOptions {
+	return func(params *PollParams) {
+		params.AggregatedNumbers = &yes
+	}
+}
+
+func PollOptionJdState() PollOptions {
+	return func(params *PollParams) {
+		params.JdState = &yes
+	}
+}
+
+func PollOptionLinkGrabberState() PollOptions {
+	return func(params *PollParams) {
+		params.LinkGrabberState = &yes
+	}
+}
+
+func DefaultPollOptions() PollOptions {
+	return func(params *PollParams) {
+		params.AggregatedNumbers = &yes
+		params.JdState = &yes
+		params.LinkGrabberState = &yes
+	}
+}
+
+// AggregatedNumbers are totals computed over whole download list
+type AggregatedNumbers struct {
+	BytesLoaded     *int64   `json:"loadedBytes,omitempty"`
+	BytesTotal      *int64   `json:"totalBytes,omitempty"`
+	DownloadSpeed   *float64 `json:"downloadSpeed,omitempty"`
+	Eta             *int64   `json:"eta,omitempty"`
+	LinkCount       *int     `json:"linkCount,omitempty"`
+	PackageCount    *int     `json:"packageCount,omitempty"`
+	RunningLinks    *int     `json:"runningLinks,omitempty"`
+	ConnectionCount *int     `json:"connections,omitempty"`
+}
+
+// PollSnapshot is result of single poll request. Only fields requested using PollOptions are set.
+type PollSnapshot struct {
+	// JdState is state of download controller, e.g. RUNNING, PAUSE, IDLE
+	JdState *string
+	// LinkGrabberState is state of link grabber, e.g. RUNNING, IDLE
+	LinkGrabberState  *string
+	AggregatedNumbers *AggregatedNumbers
+}
+
+type pollResult struct {
+	EventName string `json:"eventName"`
+	EventData struct {
+		Data json.RawMessage `json:"data"`
+	} `json:"eventData"`
+}
+
+func (d *jDevice) Poll(ctx context.Context, options ...PollOptions) (*PollSnapshot, error) {
+	params := &PollParams{}
+	if len(options) == 0 {
+		options = append(options, DefaultPollOptions())
+	}
+	for _, opt := range options {
+		opt(params)
+	}
+	data, err := d.doDevice(ctx, "/polling/poll", true, params)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]pollResult, 0)
+	if err = toObj(data, &results); err != nil {
+		return nil, err
+	}
+	snapshot := &PollSnapshot{}
+	for _, r := range results {
+		var dst interface{}
+		switch r.EventName {
+		case pollJdState:
+			dst = &snapshot.JdState
+		case pollLinkGrabberState:
+			dst = &snapshot.LinkGrabberState
+		case pollAggregatedNumbers:
+			dst = &snapshot.AggregatedNumbers
+		default:
+			d.log.Debug("ignoring unknown poll result", "event", r.EventName)
+			continue
+		}
+		if len(r.EventData.Data) == 0 {
+			continue
+		}
+		if err = json.Unmarshal(r.EventData.Data, dst); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevicePoll(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	d.Handle("/polling/poll", func(params []json.RawMessage) (interface{}, error) {
+		query := map[string]bool{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], &query))
+		assert.Equal(t, map[string]bool{"jdState": true, "aggregatedNumbers": true}, query)
+		return []map[string]interface{}{
+			{"eventName": "jdState", "eventData": map[string]interface{}{"data": "RUNNING"}},
+			{"eventName": "aggregatedNumbers", "eventData": map[string]interface{}{"data": map[string]interface{}{
+				"loadedBytes": 100, "totalBytes": 400, "downloadSpeed": 25.5, "linkCount": 2,
+			}}},
+			{"eventName": "somethingNew", "eventData": map[string]interface{}{"data": 1}},
+		}, nil
+	})
+	snapshot, err := dev.Poll(t.Context(), jdownloader.PollOptionJdState(), jdownloader.PollOptionAggregatedNumbers())
+	assert.NoError(t, err)
+	assert.Equal(t, "RUNNING", *snapshot.JdState)
+	assert.Nil(t, snapshot.LinkGrabberState)
+	assert.Equal(t, int64(100), *snapshot.AggregatedNumbers.BytesLoaded)
+	assert.Equal(t, int64(400), *snapshot.AggregatedNumbers.BytesTotal)
+	assert.Equal(t, 25.5, *snapshot.AggregatedNumbers.DownloadSpeed)
+	assert.Equal(t, 2, *snapshot.AggregatedNumbers.LinkCount)
+}
+
+func TestMockDevicePoll(t *testing.T) {
+	dev := newMockDevice(t)
+	total := int64(1000)
+	dev.SetLinks(&[]jdownloader.DownloadLink{{BytesTotal: &total}})
+	snapshot, err := dev.Poll(t.Context())
+	assert.NoError(t, err)
+	assert.NotNil(t, snapshot.JdState)
+	assert.NotNil(t, snapshot.LinkGrabberState)
+	assert.Equal(t, int64(1000), *snapshot.AggregatedNumbers.BytesTotal)
+	assert.Equal(t, 1, *snapshot.AggregatedNumbers.LinkCount)
+}
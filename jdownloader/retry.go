@@ -0,0 +1,158 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// idempotentActions is set of actions that only query state and can be safely retried
+var idempotentActions = map[string]bool{
+	"/my/listdevices":                        true,
+	"/device/ping":                           true,
+	"/device/getDirectConnectionInfos":       true,
+	"/downloadcontroller/getCurrentState":    true,
+	"/downloadcontroller/getSpeedInBps":      true,
+	"/downloadsV2/getDownloadUrls":           true,
+	"/downloadsV2/getStopMark":               true,
+	"/downloadsV2/getStopMarkedLink":         true,
+	"/downloadsV2/getStructureChangeCounter": true,
+	"/downloadsV2/queryLinks":                true,
+	"/downloadsV2/queryPackages":             true,
+	"/linkgrabberv2/getChildrenChanged":      true,
+	"/linkgrabberv2/getVariants":             true,
+	"/linkgrabberv2/isCollecting":            true,
+	"/linkgrabberv2/queryLinkCrawlerJobs":    true,
+	"/linkgrabberv2/queryLinks":              true,
+	"/linkgrabberv2/queryPackages":           true,
+	"/polling/poll":                          true,
+}
+
+// RetryPolicy controls how failed API calls are retried.
+// Every attempt is reported to callback set by ClientOptionApiCallbacks.
+type RetryPolicy struct {
+	// MaxAttempts is maximal number of attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is delay before first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps delay between two attempts
+	MaxBackoff time.Duration
+	// Multiplier is factor by which backoff grows after each attempt
+	Multiplier float64
+	// Jitter is fraction of backoff (0 to 1) which is randomized
+	Jitter float64
+	// OverloadBackoff is minimal delay used when server reports overload, rate-limiting or maintenance
+	OverloadBackoff time.Duration
+	// RetryMutating allows retrying of actions which are not known to be idempotent
+	RetryMutating bool
+	// IdempotentActions extends built-in set of actions that are safe to retry
+	IdempotentActions []string
+}
+
+// DefaultRetryPolicy creates retry policy with reasonable defaults.
+// Only idempotent queries are retried.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:     4,
+		InitialBackoff:  500 * time.Millisecond,
+		MaxBackoff:      15 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+		OverloadBackoff: 5 * time.Second,
+	}
+}
+
+// ClientOptionRetryPolicy sets policy used to retry failed API calls
+func ClientOptionRetryPolicy(policy *RetryPolicy) ClientOption {
+	return func(c *jDownloaderClient) {
+		c.retryPolicy = policy
+	}
+}
+
+func (p *RetryPolicy) canRetry(action string) bool {
+	if p.RetryMutating || idempotentActions[action] {
+		return true
+	}
+	for _, a := range p.IdempotentActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff computes delay before given attempt (starting from 1 for first retry)
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(math.Max(p.Multiplier, 1), float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		d = math.Min(d, float64(p.MaxBackoff))
+	}
+	if p.Jitter > 0 {
+		d -= d * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	if isOverload(err) {
+		d = math.Max(d, float64(p.OverloadBackoff))
+	}
+	return time.Duration(d)
+}
+
+// isOverload returns true if err indicates that server asked client to slow down
+func isOverload(err error) bool {
+	return errors.Is(err, ErrOverload) || errors.Is(err, ErrTooManyRequests) || errors.Is(err, ErrMaintenance)
+}
+
+// isRetryable returns true if err is likely transient
+func isRetryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if isOverload(err) || isTransportError(ctx, err) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// withRetry invokes fn and retries it according to retry policy, if action is safe to retry
+func (j *jDownloaderClient) withRetry(ctx context.Context, action string, fn func() ([]byte, error)) ([]byte, error) {
+	p := j.retryPolicy
+	if p == nil || p.MaxAttempts < 2 || !p.canRetry(action) {
+		return fn()
+	}
+	for attempt := 1; ; attempt++ {
+		data, err := fn()
+		if attempt >= p.MaxAttempts || !isRetryable(ctx, err) {
+			return data, err
+		}
+		delay := p.backoff(attempt, err)
+		j.log.Debug("API call failed, retrying", "action", action, "attempt", attempt, "delay", delay, "error", err)
+		if !sleepCtx(ctx, delay) {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{
+		InitialBackoff:  100 * time.Millisecond,
+		MaxBackoff:      time.Second,
+		Multiplier:      2,
+		OverloadBackoff: 5 * time.Second,
+	}
+	assert.Equal(t, 100*time.Millisecond, p.backoff(1, nil))
+	assert.Equal(t, 400*time.Millisecond, p.backoff(3, nil))
+	assert.Equal(t, time.Second, p.backoff(10, nil))
+	assert.Equal(t, 5*time.Second, p.backoff(1, ErrOverload))
+	p.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := p.backoff(1, nil)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 100*time.Millisecond)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SessionState is snapshot of session established with API server.
+// It can be used to resume session in another process without performing full login.
+type SessionState struct {
+	SessionToken          string    `json:"sessionToken"`
+	RegainToken           string    `json:"regainToken"`
+	ServerEncryptionToken []byte    `json:"serverEncryptionToken"`
+	DeviceEncryptionToken []byte    `json:"deviceEncryptionToken"`
+	RequestID             int64     `json:"rid"`
+	Timestamp             time.Time `json:"timestamp"`
+}
+
+// ClientOptionSessionState restores previously exported session, so that client don't need to perform full login.
+// If session turns out to be invalid, client reconnects transparently.
+func ClientOptionSessionState(state *SessionState) ClientOption {
+	return func(c *jDownloaderClient) {
+		if err := c.restoreSession(state); err != nil {
+			c.log.Warn("unable to restore session state", "error", err)
+		}
+	}
+}
+
+// ClientOptionSessionStateData restores session from data produced by ExportSessionState.
+// Both plain and encrypted form are accepted.
+func ClientOptionSessionStateData(data []byte) ClientOption {
+	return func(c *jDownloaderClient) {
+		state, err := c.parseSessionState(data)
+		if err == nil {
+			err = c.restoreSession(state)
+		}
+		if err != nil {
+			c.log.Warn("unable to restore session state", "error", err)
+		}
+	}
+}
+
+func (j *jDownloaderClient) SessionState() (*SessionState, error) {
+	if !j.IsConnected() {
+		return nil, errors.New("client is not connected")
+	}
+	s := j.session()
+	j.lastCallLock.Lock()
+	ts := j.lastCall
+	j.lastCallLock.Unlock()
+	return &SessionState{
+		SessionToken:          s.sessionToken,
+		RegainToken:           s.regainToken,
+		ServerEncryptionToken: append([]byte{}, s.serverEncryptionToken[:]...),
+		DeviceEncryptionToken: append([]byte{}, s.deviceEncryptionToken[:]...),
+		RequestID:             j.currentRid(),
+		Timestamp:             ts,
+	}, nil
+}
+
+func (j *jDownloaderClient) ExportSessionState(encrypted bool) ([]byte, error) {
+	state, err := j.SessionState()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return data, nil
+	}
+	ciphertext, err := encrypt(data, j.loginSecret)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+func (j *jDownloaderClient) parseSessionState(data []byte) (*SessionState, error) {
+	state := &SessionState{}
+	// 1, try plain json
+	if err := json.Unmarshal(data, state); err == nil {
+		return state, nil
+	}
+	// 2, it must be encrypted using login secret
+	decoded, err := decode(data, j.loginSecret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt session state: %v", err)
+	}
+	if err = json.Unmarshal(decoded, state); err != nil {
+		return nil, fmt.Errorf("session state is not a valid json: %v", err)
+	}
+	return state, nil
+}
+
+func (j *jDownloaderClient) restoreSession(state *SessionState) error {
+	if state == nil || state.SessionToken == "" {
+		return errors.New("session token is missing")
+	}
+	s := sessionTokens{
+		sessionToken: state.SessionToken,
+		regainToken:  state.RegainToken,
+	}
+	if len(state.ServerEncryptionToken) != len(s.serverEncryptionToken) ||
+		len(state.DeviceEncryptionToken) != len(s.deviceEncryptionToken) {
+		return errors.New("invalid length of encryption token")
+	}
+	copy(s.serverEncryptionToken[:], state.ServerEncryptionToken)
+	copy(s.deviceEncryptionToken[:], state.DeviceEncryptionToken)
+	atomic.StoreInt64(&j.counter, state.RequestID)
+	j.lastCallLock.Lock()
+	j.lastCall = state.Timestamp
+	j.lastCallLock.Unlock()
+	j.tokenLock.Lock()
+	defer j.tokenLock.Unlock()
+	j.tokens = s
+	j.connected = true
+	return nil
+}
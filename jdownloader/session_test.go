@@ -0,0 +1,66 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStateRoundTrip(t *testing.T) {
+	s := newTestServer(t)
+	s.AddDevice("dev1", "dev1")
+	c := newTestClient(s)
+	_, err := c.SessionState()
+	assert.Error(t, err)
+	assert.NoError(t, c.Connect(t.Context()))
+
+	for _, encrypted := range []bool{false, true} {
+		data, err := c.ExportSessionState(encrypted)
+		assert.NoError(t, err)
+		restored := newTestClient(s, jdownloader.ClientOptionSessionStateData(data))
+		assert.True(t, restored.IsConnected())
+		devs, err := restored.ListDevices(t.Context())
+		assert.NoError(t, err)
+		assert.Len(t, *devs, 1)
+	}
+	assert.Equal(t, 1, s.Connects())
+
+	// encrypted state can't be restored with different credentials
+	data, err := c.ExportSessionState(true)
+	assert.NoError(t, err)
+	other := jdownloader.NewClient(testEmail, "654321", slog.Default(), jdownloader.ClientOptionSessionStateData(data))
+	assert.False(t, other.IsConnected())
+}
+
+func TestRestoredSessionIsRecovered(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(s)
+	assert.NoError(t, c.Connect(t.Context()))
+	state, err := c.SessionState()
+	assert.NoError(t, err)
+	s.ExpireSession()
+
+	restored := newTestClient(s, jdownloader.ClientOptionSessionState(state))
+	_, err = restored.ListDevices(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s.Connects())
+	assert.Equal(t, 1, s.Reconnects())
+}
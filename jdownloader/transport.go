@@ -0,0 +1,103 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+)
+
+// ClientOptionHTTPClient replaces HTTP client used for all API server and device calls.
+// Client is copied, so options that are applied later (timeout, proxy, TLS config, ...) never modify given client.
+// Nil client is ignored.
+func ClientOptionHTTPClient(client *http.Client) ClientOption {
+	return func(c *jDownloaderClient) {
+		if client == nil {
+			c.log.Warn("nil HTTP client is ignored")
+			return
+		}
+		cp := *client
+		c.client = &cp
+		c.ownTransport = false
+	}
+}
+
+// ClientOptionTransport sets RoundTripper used by HTTP client, e.g. to add instrumentation
+func ClientOptionTransport(rt http.RoundTripper) ClientOption {
+	return func(c *jDownloaderClient) {
+		c.client.Transport = rt
+		c.ownTransport = false
+	}
+}
+
+// ClientOptionProxy routes all traffic through given proxy. Both HTTP(S) and SOCKS5 proxies are supported.
+func ClientOptionProxy(proxy *url.URL) ClientOption {
+	return func(c *jDownloaderClient) {
+		if t := c.httpTransport(); t != nil {
+			t.Proxy = http.ProxyURL(proxy)
+		}
+	}
+}
+
+// ClientOptionTLSConfig sets TLS configuration, e.g. to trust custom CA bundle
+func ClientOptionTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *jDownloaderClient) {
+		if t := c.httpTransport(); t != nil {
+			t.TLSClientConfig = cfg
+		}
+	}
+}
+
+// ClientOptionHeader adds HTTP header to every request
+func ClientOptionHeader(name, value string) ClientOption {
+	return func(c *jDownloaderClient) {
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		c.headers.Add(name, value)
+	}
+}
+
+// ClientOptionUserAgent sets User-Agent header of every request
+func ClientOptionUserAgent(ua string) ClientOption {
+	return func(c *jDownloaderClient) {
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		c.headers.Set("User-Agent", ua)
+	}
+}
+
+// httpTransport gets *http.Transport of HTTP client that is safe to modify.
+// Existing transport is cloned, so that shared transport (such as http.DefaultTransport) is never modified.
+// Returns nil if custom RoundTripper is in use.
+func (j *jDownloaderClient) httpTransport() *http.Transport {
+	switch t := j.client.Transport.(type) {
+	case nil:
+		j.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		if !j.ownTransport {
+			j.client.Transport = t.Clone()
+		}
+	default:
+		j.log.Warn("custom transport is in use, proxy and TLS config options are ignored")
+		return nil
+	}
+	j.ownTransport = true
+	return j.client.Transport.(*http.Transport)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.calls.Add(1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestProxyAndHeaders(t *testing.T) {
+	s := newTestServer(t)
+	s.AddDevice("dev1", "dev1").Respond("/downloadcontroller/getCurrentState", "IDLE")
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		assert.Equal(t, "api.example.invalid", r.Host)
+		assert.Equal(t, "my-agent/1.0", r.Header.Get("User-Agent"))
+		assert.Equal(t, "abc", r.Header.Get("X-Trace"))
+		s.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	proxyUrl, _ := url.Parse(proxy.URL)
+
+	c := jdownloader.NewClient(testEmail, testPassword, slog.Default(),
+		jdownloader.ClientOptionApiEndpoint("http://api.example.invalid"),
+		jdownloader.ClientOptionProxy(proxyUrl),
+		jdownloader.ClientOptionUserAgent("my-agent/1.0"),
+		jdownloader.ClientOptionHeader("X-Trace", "abc"))
+	dev, err := c.Device(t.Context(), "dev1")
+	assert.NoError(t, err)
+	state, err := dev.Downloader().State(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "IDLE", *state.State)
+	// connect, listdevices and device call
+	assert.Equal(t, int32(3), proxied.Load())
+}
+
+func TestCustomTransport(t *testing.T) {
+	s := newTestServer(t)
+	rt := &countingTransport{}
+	c := newTestClient(s,
+		jdownloader.ClientOptionHTTPClient(&http.Client{}),
+		jdownloader.ClientOptionTransport(rt))
+	_, err := c.ListDevices(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), rt.calls.Load())
+}
+
+func TestHTTPClientIsCopied(t *testing.T) {
+	s := newTestServer(t)
+	hc := &http.Client{Timeout: time.Minute}
+	c := newTestClient(s,
+		jdownloader.ClientOptionHTTPClient(nil),
+		jdownloader.ClientOptionHTTPClient(hc),
+		jdownloader.ClientOptionTimeout(time.Second),
+		jdownloader.ClientOptionProxy(&url.URL{Scheme: "http", Host: "127.0.0.1:1"}))
+	assert.NotNil(t, c)
+	assert.Equal(t, time.Minute, hc.Timeout)
+	assert.Nil(t, hc.Transport)
+}
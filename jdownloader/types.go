@@ -17,6 +17,7 @@ limitations under the License.
 package jdownloader
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"time"
@@ -54,20 +55,25 @@ type DataResponse struct {
 
 type JdClient interface {
 	// Connect connects to device and obtains session key
-	Connect() error
+	Connect(context.Context) error
 	// IsConnected returns true if client is connected to API server
 	IsConnected() bool
 	// Reconnect reconnects client to API server
-	Reconnect() error
+	Reconnect(context.Context) error
 	// Disconnect disconnects client from API server
-	Disconnect() error
+	Disconnect(context.Context) error
 	// ListDevices lists all devices associated with account used to connect to API server
-	ListDevices() (*[]DeviceInfo, error)
+	ListDevices(context.Context) (*[]DeviceInfo, error)
 	// Device gets specific device instance based on device name
-	Device(string) (Device, error)
+	Device(context.Context, string) (Device, error)
 	// ConfigHash return hash code of configuration.
 	// This method can be used to determine if there was configuration change
 	ConfigHash() string
+	// SessionState gets snapshot of current session, which can be later restored using ClientOptionSessionState
+	SessionState() (*SessionState, error)
+	// ExportSessionState serializes current session, optionally encrypted using login secret.
+	// Result can be restored using ClientOptionSessionStateData
+	ExportSessionState(encrypted bool) ([]byte, error)
 }
 
 func NewClient(email string, password string, logger *slog.Logger, opts ...ClientOption) JdClient {
@@ -75,7 +81,7 @@ func NewClient(email string, password string, logger *slog.Logger, opts ...Clien
 		connected: false,
 		email:     email,
 		appKey:    appName,
-		client: http.Client{
+		client: &http.Client{
 			Timeout: 15 * time.Second,
 		},
 		loginSecret:  createSecret(email, password, "server"),
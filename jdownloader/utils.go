@@ -17,11 +17,13 @@ limitations under the License.
 package jdownloader
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/url"
+	"time"
 )
 
 func toObj(response *DataResponse, dst interface{}) error {
@@ -35,10 +37,10 @@ func toObj(response *DataResponse, dst interface{}) error {
 	return json.Unmarshal(data, dst)
 }
 
-func parseError(data []byte, key [32]byte, log *slog.Logger) map[string]interface{} {
+func parseError(data []byte, key [32]byte, log *slog.Logger) (*DataResponse, []byte) {
 	// 1, try simple json unmarshal
-	var v map[string]interface{}
-	err := json.Unmarshal(data, &v)
+	v := &DataResponse{}
+	err := json.Unmarshal(data, v)
 	// 2, if that doesn't work, it must be encrypted
 	if err != nil {
 		var decoded []byte
@@ -46,16 +48,24 @@ func parseError(data []byte, key [32]byte, log *slog.Logger) map[string]interfac
 			log.Warn("unable to decrypt error response", "error", err)
 		} else {
 			// 3, now try to unmarshal error
-			if err = json.Unmarshal(decoded, &v); err != nil {
+			if err = json.Unmarshal(decoded, v); err != nil {
 				log.Warn("error response is not a json", "error", err)
-				return nil
+				return nil, decoded
 			}
-			return v
+			return v, decoded
 		}
 	} else {
-		return v
+		return v, data
 	}
-	return nil
+	return nil, data
+}
+
+// uuids normalizes array of link or package UUIDs, so that nil is sent to device as empty array rather than null
+func uuids(ids []int64) []int64 {
+	if ids == nil {
+		return []int64{}
+	}
+	return ids
 }
 
 // qp Creates escaped query parameter
@@ -69,3 +79,15 @@ func bodycloser(b io.ReadCloser, log *slog.Logger) {
 		log.Warn("error while closing reader", "error", err)
 	}
 }
+
+// sleepCtx waits for given duration, returns false if context was cancelled in the meantime
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sync"
+)
+
+func changeCounter(ctx context.Context, d *jDevice, action string, old int64) (int64, error) {
+	data, err := d.doDevice(ctx, action, false, old)
+	if err != nil {
+		return 0, err
+	}
+	counter := int64(-1)
+	if err = toObj(data, &counter); err != nil {
+		return 0, err
+	}
+	return counter, nil
+}
+
+// DownloadView is cached copy of download list. Whole list is fetched only when its structure changes,
+// otherwise only volatile fields of links and packages are refreshed.
+type DownloadView struct {
+	dl       Downloader
+	lock     sync.RWMutex
+	counter  int64
+	links    []DownloadLink
+	packages []DownloadPackage
+	// linkIndex and packageIndex map UUID to position in links and packages
+	linkIndex    map[int64]int
+	packageIndex map[int64]int
+}
+
+// NewDownloadView creates view of download list. View is empty until first Refresh.
+func NewDownloadView(dl Downloader) *DownloadView {
+	return &DownloadView{dl: dl, counter: -1}
+}
+
+func volatileLinkOptions() DownloadQueryLinksOptions {
+	return func(params *DownloadQueryLinksParams) {
+		params.BytesLoaded = &yes
+		params.Eta = &yes
+		params.ExtractionStatus = &yes
+		params.Finished = &yes
+		params.Running = &yes
+		params.Speed = &yes
+		params.Status = &yes
+	}
+}
+
+func volatilePackageOptions() DownloadQueryPackagesOptions {
+	return func(params *DownloadQueryPackagesParams) {
+		params.BytesLoaded = &yes
+		params.Eta = &yes
+		params.Finished = &yes
+		params.Running = &yes
+		params.Speed = &yes
+		params.Status = &yes
+	}
+}
+
+// Refresh updates view from device. It reports whether structure of list changed and whole list was fetched.
+func (v *DownloadView) Refresh(ctx context.Context) (bool, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	counter, err := v.dl.StructureChangeCounter(ctx, v.counter)
+	if err != nil {
+		return false, err
+	}
+	if counter != -1 && counter != v.counter {
+		return true, v.fetch(ctx, counter)
+	}
+	complete, err := v.update(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !complete {
+		// list changed between calls, counter will be re-read on next refresh
+		return true, v.fetch(ctx, -1)
+	}
+	return false, nil
+}
+
+// fetch fetches whole list. Caller must hold lock.
+func (v *DownloadView) fetch(ctx context.Context, counter int64) error {
+	links, err := collect(DownloadLinks(ctx, v.dl, 0))
+	if err != nil {
+		return err
+	}
+	packages, err := collect(DownloadPackages(ctx, v.dl, 0))
+	if err != nil {
+		return err
+	}
+	v.links, v.packages, v.counter = links, packages, counter
+	v.linkIndex = indexByUuid(links, func(l DownloadLink) *int64 { return l.Uuid })
+	v.packageIndex = indexByUuid(packages, func(p DownloadPackage) *int64 { return p.Uuid })
+	return nil
+}
+
+// update refreshes volatile fields. It returns false if some item is not in view. Caller must hold lock.
+func (v *DownloadView) update(ctx context.Context) (bool, error) {
+	count := 0
+	for fresh, err := range DownloadLinks(ctx, v.dl, 0, volatileLinkOptions()) {
+		if err != nil {
+			return false, err
+		}
+		i, found := lookup(v.linkIndex, fresh.Uuid)
+		if !found {
+			return false, nil
+		}
+		l := &v.links[i]
+		l.BytesLoaded, l.Eta, l.ExtractionStatus, l.Finished = fresh.BytesLoaded, fresh.Eta, fresh.ExtractionStatus, fresh.Finished
+		l.Running, l.Speed, l.Status = fresh.Running, fresh.Speed, fresh.Status
+		count++
+	}
+	if count != len(v.links) {
+		return false, nil
+	}
+	count = 0
+	for fresh, err := range DownloadPackages(ctx, v.dl, 0, volatilePackageOptions()) {
+		if err != nil {
+			return false, err
+		}
+		i, found := lookup(v.packageIndex, fresh.Uuid)
+		if !found {
+			return false, nil
+		}
+		p := &v.packages[i]
+		p.BytesLoaded, p.Eta, p.Finished = fresh.BytesLoaded, fresh.Eta, fresh.Finished
+		p.Running, p.Speed, p.Status = fresh.Running, fresh.Speed, fresh.Status
+		count++
+	}
+	return count == len(v.packages), nil
+}
+
+// Links gets copy of cached links
+func (v *DownloadView) Links() []DownloadLink {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return slices.Clone(v.links)
+}
+
+// Packages gets copy of cached packages
+func (v *DownloadView) Packages() []DownloadPackage {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return slices.Clone(v.packages)
+}
+
+// LinkGrabberView is cached copy of link grabber list. Whole list is fetched only when its structure changes,
+// otherwise only availability and status of links and availability counts of packages are refreshed.
+type LinkGrabberView struct {
+	lg           LinkGrabber
+	lock         sync.RWMutex
+	counter      int64
+	links        []CrawledLink
+	packages     []CrawledPackage
+	linkIndex    map[int64]int
+	packageIndex map[int64]int
+}
+
+// NewLinkGrabberView creates view of link grabber list. View is empty until first Refresh.
+func NewLinkGrabberView(lg LinkGrabber) *LinkGrabberView {
+	return &LinkGrabberView{lg: lg, counter: -1}
+}
+
+func volatileCrawledLinkOptions() LinkGrabberQueryLinksOptions {
+	return func(params *LinkGrabberQueryLinksParams) {
+		params.Availability = &yes
+		params.Status = &yes
+	}
+}
+
+func volatileCrawledPackageOptions() LinkGrabberQueryPackagesOptions {
+	return func(params *QueryPackagesParams) {
+		params.AvailableOfflineCount = &yes
+		params.AvailableOnlineCount = &yes
+		params.AvailableTempUnknownCount = &yes
+		params.AvailableUnknownCount = &yes
+	}
+}
+
+// Refresh updates view from device. It reports whether structure of list changed and whole list was fetched.
+func (v *LinkGrabberView) Refresh(ctx context.Context) (bool, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	counter, err := v.lg.ChildrenChanged(ctx, v.counter)
+	if err != nil {
+		return false, err
+	}
+	if counter != -1 && counter != v.counter {
+		return true, v.fetch(ctx, counter)
+	}
+	complete, err := v.update(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !complete {
+		// list changed between calls, counter will be re-read on next refresh
+		return true, v.fetch(ctx, -1)
+	}
+	return false, nil
+}
+
+// fetch fetches whole list. Caller must hold lock.
+func (v *LinkGrabberView) fetch(ctx context.Context, counter int64) error {
+	links, err := collect(CrawledLinks(ctx, v.lg, 0))
+	if err != nil {
+		return err
+	}
+	packages, err := collect(CrawledPackages(ctx, v.lg, 0))
+	if err != nil {
+		return err
+	}
+	v.links, v.packages, v.counter = links, packages, counter
+	v.linkIndex = indexByUuid(links, func(l CrawledLink) *int64 { return l.Uuid })
+	v.packageIndex = indexByUuid(packages, func(p CrawledPackage) *int64 { return p.Uuid })
+	return nil
+}
+
+// update refreshes volatile fields. It returns false if some item is not in view. Caller must hold lock.
+func (v *LinkGrabberView) update(ctx context.Context) (bool, error) {
+	count := 0
+	for fresh, err := range CrawledLinks(ctx, v.lg, 0, volatileCrawledLinkOptions()) {
+		if err != nil {
+			return false, err
+		}
+		i, found := lookup(v.linkIndex, fresh.Uuid)
+		if !found {
+			return false, nil
+		}
+		v.links[i].Availability, v.links[i].Status = fresh.Availability, fresh.Status
+		count++
+	}
+	if count != len(v.links) {
+		return false, nil
+	}
+	count = 0
+	for fresh, err := range CrawledPackages(ctx, v.lg, 0, volatileCrawledPackageOptions()) {
+		if err != nil {
+			return false, err
+		}
+		i, found := lookup(v.packageIndex, fresh.Uuid)
+		if !found {
+			return false, nil
+		}
+		p := &v.packages[i]
+		p.OnlineCount, p.OfflineCount, p.UnknownCount, p.TempUnknownCount =
+			fresh.OnlineCount, fresh.OfflineCount, fresh.UnknownCount, fresh.TempUnknownCount
+		count++
+	}
+	return count == len(v.packages), nil
+}
+
+// Links gets copy of cached links
+func (v *LinkGrabberView) Links() []CrawledLink {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return slices.Clone(v.links)
+}
+
+// Packages gets copy of cached packages
+func (v *LinkGrabberView) Packages() []CrawledPackage {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return slices.Clone(v.packages)
+}
+
+// collect gathers all items of sequence, stopping at first error
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	res := make([]T, 0)
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+// indexByUuid maps UUID of each item to its position. Items without UUID are left out.
+func indexByUuid[T any](items []T, uuid func(T) *int64) map[int64]int {
+	index := make(map[int64]int, len(items))
+	for i, item := range items {
+		if id := uuid(item); id != nil {
+			index[*id] = i
+		}
+	}
+	return index
+}
+
+// lookup finds position of item with given UUID
+func lookup(index map[int64]int, uuid *int64) (int, bool) {
+	if uuid == nil {
+		return 0, false
+	}
+	i, found := index[*uuid]
+	return i, found
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader/jdtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadView(t *testing.T) {
+	_, d, dev := newTestDevice(t)
+	var counter, loaded, fullQueries atomic.Int64
+	counter.Store(5)
+	d.Handle("/downloadsV2/getStructureChangeCounter", func(params []json.RawMessage) (interface{}, error) {
+		var old int64
+		assert.NoError(t, jdtest.DecodeParam(params[0], &old))
+		if old == counter.Load() {
+			return -1, nil
+		}
+		return counter.Load(), nil
+	})
+	d.Handle("/downloadsV2/queryLinks", func(params []json.RawMessage) (interface{}, error) {
+		query := &jdownloader.DownloadQueryLinksParams{}
+		assert.NoError(t, jdtest.DecodeParam(params[0], query))
+		link := jdownloader.DownloadLink{Uuid: ptr(int64(1)), BytesLoaded: ptr(loaded.Load())}
+		if query.Url != nil {
+			fullQueries.Add(1)
+			link.Name, link.Url = ptr("a.zip"), ptr("https://a.tld/a.zip")
+		}
+		return []jdownloader.DownloadLink{link}, nil
+	})
+	d.Respond("/downloadsV2/queryPackages", []jdownloader.DownloadPackage{{Uuid: ptr(int64(10))}})
+	view := jdownloader.NewDownloadView(dev.Downloader())
+
+	changed, err := view.Refresh(t.Context())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, int64(1), fullQueries.Load())
+
+	loaded.Store(100)
+	changed, err = view.Refresh(t.Context())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, int64(1), fullQueries.Load())
+	links := view.Links()
+	assert.Equal(t, int64(100), *links[0].BytesLoaded)
+	assert.Equal(t, "a.zip", *links[0].Name, "non-volatile fields should be kept")
+
+	counter.Add(1)
+	changed, err = view.Refresh(t.Context())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, int64(2), fullQueries.Load())
+	assert.Len(t, view.Packages(), 1)
+}
+
+func TestMockViews(t *testing.T) {
+	ctx := t.Context()
+	dev := newMockDevice(t)
+	lgView := jdownloader.NewLinkGrabberView(dev.LinkGrabber())
+	dlView := jdownloader.NewDownloadView(dev.Downloader())
+
+	_, err := dev.LinkGrabber().Add(ctx, []string{"https://a.tld/1"}, jdownloader.AddLinksOptionAutostart(false))
+	assert.NoError(t, err)
+	changed, err := lgView.Refresh(ctx)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Len(t, lgView.Links(), 1)
+	changed, _ = lgView.Refresh(ctx)
+	assert.False(t, changed)
+
+	// availability is refreshed without fetching whole list
+	link := *lgView.Links()[0].Uuid
+	assert.NoError(t, dev.UpdateCrawledLink(link, func(l *jdownloader.CrawledLink) {
+		l.Availability = ptr("OFFLINE")
+	}))
+	changed, _ = lgView.Refresh(ctx)
+	assert.False(t, changed)
+	assert.Equal(t, "OFFLINE", *lgView.Links()[0].Availability)
+	assert.Equal(t, 1, *lgView.Packages()[0].OfflineCount)
+
+	pkg := *lgView.Packages()[0].Uuid
+	assert.NoError(t, dev.LinkGrabber().MoveToDownloadList(ctx, nil, []int64{pkg}))
+	changed, _ = lgView.Refresh(ctx)
+	assert.True(t, changed)
+	assert.Empty(t, lgView.Links())
+
+	changed, _ = dlView.Refresh(ctx)
+	assert.True(t, changed)
+	uuid := *dlView.Links()[0].Uuid
+	assert.NoError(t, dev.UpdateLink(uuid, func(l *jdownloader.DownloadLink) {
+		l.BytesLoaded = ptr(int64(42))
+	}))
+	changed, _ = dlView.Refresh(ctx)
+	assert.False(t, changed)
+	assert.Equal(t, int64(42), *dlView.Links()[0].BytesLoaded)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const extractionSuccessful = "SUCCESSFUL"
+
+var archiveName = regexp.MustCompile(`\.(zip|rar|7z|tar|gz|tgz|bz2|tbz2|xz|txz|r\d{2}|\d{3})$`)
+
+// LinkFailedError is returned by wait helpers when link can't be downloaded or extracted
+type LinkFailedError struct {
+	Link   DownloadLink
+	Reason string
+}
+
+func (e *LinkFailedError) Error() string {
+	var uuid int64
+	if e.Link.Uuid != nil {
+		uuid = *e.Link.Uuid
+	}
+	return fmt.Sprintf("link %d failed: %s", uuid, e.Reason)
+}
+
+// WaitProgress is reported to progress callback after every check
+type WaitProgress struct {
+	Links       []DownloadLink
+	Finished    int
+	BytesLoaded int64
+	BytesTotal  int64
+}
+
+type WaitParams struct {
+	// Interval between two checks
+	Interval time.Duration
+	// Extraction when true, links are considered done only after successful extraction
+	Extraction bool
+	// Archive decides which links are expected to be extracted, others are done once finished.
+	// When nil, every link is expected to be extracted.
+	Archive func(DownloadLink) bool
+	// Progress is invoked after every check
+	Progress func(WaitProgress)
+	// Failed returns non-empty reason if link should be considered failed
+	Failed func(DownloadLink) string
+}
+
+type WaitOptions func(params *WaitParams)
+
+func WaitOptionInterval(interval time.Duration) WaitOptions {
+	return func(params *WaitParams) {
+		params.Interval = interval
+	}
+}
+
+func WaitOptionExtraction() WaitOptions {
+	return func(params *WaitParams) {
+		params.Extraction = true
+	}
+}
+
+// WaitOptionArchive restricts extraction requirement to links for which fn returns true, such as ArchiveByName
+func WaitOptionArchive(fn func(DownloadLink) bool) WaitOptions {
+	return func(params *WaitParams) {
+		params.Archive = fn
+	}
+}
+
+// ArchiveByName considers link archive when its name has extension of archive format supported by JDownloader,
+// including multi-volume archives such as .part1.rar or .7z.001
+func ArchiveByName(link DownloadLink) bool {
+	if link.Name == nil {
+		return false
+	}
+	return archiveName.MatchString(strings.ToLower(*link.Name))
+}
+
+func WaitOptionProgress(fn func(WaitProgress)) WaitOptions {
+	return func(params *WaitParams) {
+		params.Progress = fn
+	}
+}
+
+func WaitOptionFailed(fn func(DownloadLink) string) WaitOptions {
+	return func(params *WaitParams) {
+		params.Failed = fn
+	}
+}
+
+// DefaultLinkFailed considers link failed when it's skipped, marked with error icon or its extraction failed
+func DefaultLinkFailed(link DownloadLink) string {
+	if isTrue(link.Skipped) {
+		return "skipped"
+	}
+	if link.StatusIconKey != nil && (*link.StatusIconKey == "false" || *link.StatusIconKey == "error") {
+		if link.Status != nil {
+			return *link.Status
+		}
+		return "error"
+	}
+	if link.ExtractionStatus != nil && strings.HasPrefix(*link.ExtractionStatus, "ERR") {
+		return "extraction: " + *link.ExtractionStatus
+	}
+	return ""
+}
+
+// WaitForPackage blocks until all links of given download package are finished.
+// It returns final state of package links.
+func WaitForPackage(ctx context.Context, dl Downloader, uuid int64, options ...WaitOptions) ([]DownloadLink, error) {
+	query := []DownloadQueryLinksOptions{DefaultDownloadQueryLinksOptions(), DownloadQueryLinksOptionPackageUUIDs(uuid)}
+	return waitFor(ctx, dl, query, func(links []DownloadLink) ([]DownloadLink, error) {
+		res := make([]DownloadLink, 0)
+		for _, l := range links {
+			if l.PackageUuid != nil && *l.PackageUuid == uuid {
+				res = append(res, l)
+			}
+		}
+		if len(res) == 0 {
+			return nil, fmt.Errorf("package %d has no links", uuid)
+		}
+		return res, nil
+	}, options...)
+}
+
+// WaitForLinks blocks until all given download links are finished.
+// It returns final state of links in the same order as requested.
+func WaitForLinks(ctx context.Context, dl Downloader, uuids []int64, options ...WaitOptions) ([]DownloadLink, error) {
+	return waitFor(ctx, dl, nil, func(links []DownloadLink) ([]DownloadLink, error) {
+		byId := make(map[int64]DownloadLink, len(links))
+		for _, l := range links {
+			if l.Uuid != nil {
+				byId[*l.Uuid] = l
+			}
+		}
+		res := make([]DownloadLink, 0, len(uuids))
+		for _, uuid := range uuids {
+			l, found := byId[uuid]
+			if !found {
+				return nil, fmt.Errorf("link %d not found", uuid)
+			}
+			res = append(res, l)
+		}
+		return res, nil
+	}, options...)
+}
+
+func waitFor(ctx context.Context, dl Downloader, query []DownloadQueryLinksOptions,
+	selectFn func([]DownloadLink) ([]DownloadLink, error), options ...WaitOptions) ([]DownloadLink, error) {
+	params := &WaitParams{
+		Interval: 5 * time.Second,
+		Failed:   DefaultLinkFailed,
+	}
+	for _, opt := range options {
+		opt(params)
+	}
+	if params.Interval <= 0 {
+		return nil, fmt.Errorf("invalid wait interval: %v", params.Interval)
+	}
+	for {
+		items, err := dl.Links(ctx, query...)
+		if err != nil {
+			return nil, err
+		}
+		links, err := selectFn(*items)
+		if err != nil {
+			return nil, err
+		}
+		progress := WaitProgress{Links: links}
+		for _, l := range links {
+			if reason := params.Failed(l); reason != "" {
+				return links, &LinkFailedError{Link: l, Reason: reason}
+			}
+			if l.BytesLoaded != nil {
+				progress.BytesLoaded += *l.BytesLoaded
+			}
+			if l.BytesTotal != nil {
+				progress.BytesTotal += *l.BytesTotal
+			}
+			extracted := l.ExtractionStatus != nil && *l.ExtractionStatus == extractionSuccessful
+			if isTrue(l.Finished) && (!params.Extraction || extracted || (params.Archive != nil && !params.Archive(l))) {
+				progress.Finished++
+			}
+		}
+		if params.Progress != nil {
+			params.Progress(progress)
+		}
+		if progress.Finished == len(links) {
+			return links, nil
+		}
+		if !sleepCtx(ctx, params.Interval) {
+			return nil, ctx.Err()
+		}
+	}
+}
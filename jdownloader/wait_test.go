@@ -0,0 +1,138 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForPackage(t *testing.T) {
+	dev := newMockDevice(t)
+	dev.SetLinks(&[]jdownloader.DownloadLink{watchedLink(1, 10), watchedLink(2, 10), watchedLink(3, 11)})
+
+	var checks atomic.Int32
+	links, err := jdownloader.WaitForPackage(t.Context(), dev.Downloader(), 10,
+		jdownloader.WaitOptionInterval(time.Millisecond),
+		jdownloader.WaitOptionExtraction(),
+		jdownloader.WaitOptionProgress(func(p jdownloader.WaitProgress) {
+			assert.Len(t, p.Links, 2)
+			assert.Equal(t, int64(200), p.BytesTotal)
+			// finish one link per check, extraction follows on next check
+			n := checks.Add(1)
+			for _, id := range []int64{1, 2} {
+				_ = dev.UpdateLink(id, func(l *jdownloader.DownloadLink) {
+					if int64(n) == id {
+						l.Finished = ptr(true)
+						l.BytesLoaded = l.BytesTotal
+						l.ExtractionStatus = ptr("RUNNING")
+					}
+					if int64(n) > id {
+						l.ExtractionStatus = ptr("SUCCESSFUL")
+					}
+				})
+			}
+		}))
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+	assert.Equal(t, int32(4), checks.Load())
+}
+
+func TestWaitForLinksFailsFast(t *testing.T) {
+	dev := newMockDevice(t)
+	failed := watchedLink(2, 10)
+	failed.StatusIconKey = ptr("false")
+	failed.Status = ptr("File not found")
+	dev.SetLinks(&[]jdownloader.DownloadLink{watchedLink(1, 10), failed})
+
+	_, err := jdownloader.WaitForLinks(t.Context(), dev.Downloader(), []int64{1, 2},
+		jdownloader.WaitOptionInterval(time.Millisecond))
+	var lfe *jdownloader.LinkFailedError
+	assert.True(t, errors.As(err, &lfe))
+	assert.Equal(t, "File not found", lfe.Reason)
+
+	_, err = jdownloader.WaitForLinks(t.Context(), dev.Downloader(), []int64{3},
+		jdownloader.WaitOptionInterval(time.Millisecond))
+	assert.Error(t, err)
+}
+
+func TestWaitForPackageMixedExtraction(t *testing.T) {
+	dev := newMockDevice(t)
+	archive, plain := watchedLink(1, 10), watchedLink(2, 10)
+	archive.Name, plain.Name = ptr("a.part1.rar"), ptr("b.mkv")
+	dev.SetLinks(&[]jdownloader.DownloadLink{archive, plain})
+
+	var checks atomic.Int32
+	links, err := jdownloader.WaitForPackage(t.Context(), dev.Downloader(), 10,
+		jdownloader.WaitOptionInterval(time.Millisecond),
+		jdownloader.WaitOptionExtraction(),
+		jdownloader.WaitOptionArchive(jdownloader.ArchiveByName),
+		jdownloader.WaitOptionProgress(func(p jdownloader.WaitProgress) {
+			switch checks.Add(1) {
+			case 1:
+				assert.Equal(t, 0, p.Finished)
+				// both downloads finish, extraction of archive didn't start yet
+				for _, id := range []int64{1, 2} {
+					_ = dev.UpdateLink(id, func(l *jdownloader.DownloadLink) { l.Finished = ptr(true) })
+				}
+			case 2:
+				assert.Equal(t, 1, p.Finished)
+				_ = dev.UpdateLink(1, func(l *jdownloader.DownloadLink) { l.ExtractionStatus = ptr("RUNNING") })
+			case 3:
+				assert.Equal(t, 1, p.Finished)
+				_ = dev.UpdateLink(1, func(l *jdownloader.DownloadLink) { l.ExtractionStatus = ptr("SUCCESSFUL") })
+			}
+		}))
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+	assert.Equal(t, int32(4), checks.Load())
+}
+
+func TestWaitForPackageExtractionNotStarted(t *testing.T) {
+	dev := newMockDevice(t)
+	finished := watchedLink(1, 10)
+	finished.Finished = ptr(true)
+	dev.SetLinks(&[]jdownloader.DownloadLink{finished})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err := jdownloader.WaitForPackage(ctx, dev.Downloader(), 10,
+		jdownloader.WaitOptionInterval(time.Millisecond),
+		jdownloader.WaitOptionExtraction())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestArchiveByName(t *testing.T) {
+	for name, archive := range map[string]bool{
+		"a.zip": true, "a.part2.rar": true, "a.r01": true, "a.7z.001": true, "a.tar.gz": true,
+		"a.mkv": false, "a.iso": false, "zip": false,
+	} {
+		assert.Equal(t, archive, jdownloader.ArchiveByName(jdownloader.DownloadLink{Name: &name}), name)
+	}
+}
+
+func TestWaitInvalidInterval(t *testing.T) {
+	dev := newMockDevice(t)
+	_, err := jdownloader.WaitForPackage(t.Context(), dev.Downloader(), 10, jdownloader.WaitOptionInterval(0))
+	assert.Error(t, err)
+}
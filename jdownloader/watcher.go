@@ -0,0 +1,286 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	ChangeLinkAdded            = ChangeType("LINK_ADDED")
+	ChangeLinkRemoved          = ChangeType("LINK_REMOVED")
+	ChangeLinkProgress         = ChangeType("LINK_PROGRESS")
+	ChangeLinkStatus           = ChangeType("LINK_STATUS")
+	ChangeLinkFinished         = ChangeType("LINK_FINISHED")
+	ChangeLinkExtractionStatus = ChangeType("LINK_EXTRACTION_STATUS")
+	ChangePackageFinished      = ChangeType("PACKAGE_FINISHED")
+)
+
+// Change describes single difference between two consecutive snapshots of download list
+type Change struct {
+	Type ChangeType
+	// Uuid is UUID of affected link, or package in case of ChangePackageFinished
+	Uuid int64
+	// Link is current state of link. For ChangeLinkRemoved it's last known state.
+	Link *DownloadLink
+	// Previous is state of link in previous snapshot, nil for ChangeLinkAdded
+	Previous *DownloadLink
+	// Package is set for ChangePackageFinished
+	Package *DownloadPackage
+}
+
+type WatchParams struct {
+	// Interval between two snapshots
+	Interval time.Duration
+	// Changes restricts emitted change types, all types are emitted when empty
+	Changes []ChangeType
+	// BufferSize is capacity of returned channel
+	BufferSize int
+	// ErrorHandler is invoked when snapshot can't be taken. Watcher keeps polling regardless.
+	ErrorHandler func(error)
+	// LinkOptions are passed to Downloader.Links, defaults are used when empty
+	LinkOptions []DownloadQueryLinksOptions
+}
+
+type WatchOptions func(params *WatchParams)
+
+func WatchOptionInterval(interval time.Duration) WatchOptions {
+	return func(params *WatchParams) {
+		params.Interval = interval
+	}
+}
+
+func WatchOptionChanges(changes ...ChangeType) WatchOptions {
+	return func(params *WatchParams) {
+		params.Changes = changes
+	}
+}
+
+func WatchOptionBufferSize(size int) WatchOptions {
+	return func(params *WatchParams) {
+		params.BufferSize = size
+	}
+}
+
+func WatchOptionErrorHandler(fn func(error)) WatchOptions {
+	return func(params *WatchParams) {
+		params.ErrorHandler = fn
+	}
+}
+
+func WatchOptionLinkOptions(options ...DownloadQueryLinksOptions) WatchOptions {
+	return func(params *WatchParams) {
+		params.LinkOptions = options
+	}
+}
+
+func (p *WatchParams) wants(ct ChangeType) bool {
+	return len(p.Changes) == 0 || slices.Contains(p.Changes, ct)
+}
+
+type watcher struct {
+	dl     Downloader
+	params *WatchParams
+	links  map[int64]DownloadLink
+	// finished holds UUIDs of packages which were already complete in last snapshot
+	finished map[int64]bool
+}
+
+// Watch periodically snapshots download list and emits differences between consecutive snapshots.
+// Links are keyed by Uuid, links without Uuid are ignored. Initial snapshot is taken synchronously
+// and serves as baseline, so no changes are emitted for links that already exist.
+// Returned channel is closed once ctx is done.
+func Watch(ctx context.Context, dl Downloader, options ...WatchOptions) (<-chan Change, error) {
+	params := &WatchParams{
+		Interval:     5 * time.Second,
+		BufferSize:   64,
+		ErrorHandler: func(error) {},
+	}
+	for _, opt := range options {
+		opt(params)
+	}
+	if params.Interval <= 0 {
+		return nil, fmt.Errorf("invalid watch interval: %v", params.Interval)
+	}
+	w := &watcher{dl: dl, params: params}
+	links, err := w.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w.links = links
+	w.finished = finishedPackages(links)
+	ch := make(chan Change, params.BufferSize)
+	go w.run(ctx, ch)
+	return ch, nil
+}
+
+func (w *watcher) run(ctx context.Context, ch chan<- Change) {
+	defer close(ch)
+	ticker := time.NewTicker(w.params.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		links, err := w.snapshot(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.params.ErrorHandler(err)
+			continue
+		}
+		changes, err := w.diff(ctx, links)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			w.params.ErrorHandler(err)
+			continue
+		}
+		for _, c := range changes {
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (w *watcher) snapshot(ctx context.Context) (map[int64]DownloadLink, error) {
+	items, err := w.dl.Links(ctx, w.params.LinkOptions...)
+	if err != nil {
+		return nil, err
+	}
+	links := make(map[int64]DownloadLink, len(*items))
+	for _, l := range *items {
+		if l.Uuid != nil {
+			links[*l.Uuid] = l
+		}
+	}
+	return links, nil
+}
+
+func (w *watcher) diff(ctx context.Context, links map[int64]DownloadLink) ([]Change, error) {
+	changes := make([]Change, 0)
+	emit := func(ct ChangeType, uuid int64, cur, prev *DownloadLink) {
+		if w.params.wants(ct) {
+			changes = append(changes, Change{Type: ct, Uuid: uuid, Link: cur, Previous: prev})
+		}
+	}
+	for _, uuid := range sortedKeys(links) {
+		cur := links[uuid]
+		prev, found := w.links[uuid]
+		if !found {
+			emit(ChangeLinkAdded, uuid, &cur, nil)
+			if isTrue(cur.Finished) {
+				emit(ChangeLinkFinished, uuid, &cur, nil)
+			}
+			continue
+		}
+		if !ptrEqual(cur.BytesLoaded, prev.BytesLoaded) {
+			emit(ChangeLinkProgress, uuid, &cur, &prev)
+		}
+		if !ptrEqual(cur.Status, prev.Status) {
+			emit(ChangeLinkStatus, uuid, &cur, &prev)
+		}
+		if isTrue(cur.Finished) && !isTrue(prev.Finished) {
+			emit(ChangeLinkFinished, uuid, &cur, &prev)
+		}
+		if !ptrEqual(cur.ExtractionStatus, prev.ExtractionStatus) {
+			emit(ChangeLinkExtractionStatus, uuid, &cur, &prev)
+		}
+	}
+	for _, uuid := range sortedKeys(w.links) {
+		if _, found := links[uuid]; !found {
+			prev := w.links[uuid]
+			emit(ChangeLinkRemoved, uuid, &prev, nil)
+		}
+	}
+	finished := finishedPackages(links)
+	if w.params.wants(ChangePackageFinished) {
+		var pkgs *[]DownloadPackage
+		for _, uuid := range sortedKeys(finished) {
+			if w.finished[uuid] {
+				continue
+			}
+			if pkgs == nil {
+				var err error
+				if pkgs, err = w.dl.Packages(ctx); err != nil {
+					return nil, err
+				}
+			}
+			change := Change{Type: ChangePackageFinished, Uuid: uuid}
+			for i := range *pkgs {
+				if p := (*pkgs)[i]; p.Uuid != nil && *p.Uuid == uuid {
+					change.Package = &p
+					break
+				}
+			}
+			changes = append(changes, change)
+		}
+	}
+	w.links = links
+	w.finished = finished
+	return changes, nil
+}
+
+// finishedPackages gets UUIDs of packages whose links are all finished
+func finishedPackages(links map[int64]DownloadLink) map[int64]bool {
+	res := make(map[int64]bool)
+	for _, l := range links {
+		if l.PackageUuid == nil {
+			continue
+		}
+		done, seen := res[*l.PackageUuid]
+		res[*l.PackageUuid] = (done || !seen) && isTrue(l.Finished)
+	}
+	for uuid, done := range res {
+		if !done {
+			delete(res, uuid)
+		}
+	}
+	return res
+}
+
+func sortedKeys[V any](m map[int64]V) []int64 {
+	keys := make([]int64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func ptrEqual[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 Richard Kosegi
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jdownloader_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rkosegi/jdownloader-go/v2/jdownloader"
+	"github.com/stretchr/testify/assert"
+)
+
+func watchedLink(uuid, pkg int64) jdownloader.DownloadLink {
+	loaded, total, status := int64(0), int64(100), "Waiting"
+	finished := false
+	return jdownloader.DownloadLink{Uuid: &uuid, PackageUuid: &pkg, BytesLoaded: &loaded,
+		BytesTotal: &total, Status: &status, Finished: &finished}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func nextChange(t *testing.T, ch <-chan jdownloader.Change) jdownloader.Change {
+	select {
+	case c := <-ch:
+		return c
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for change")
+		return jdownloader.Change{}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dev := newMockDevice(t)
+	name := "pkg"
+	pkgId := int64(10)
+	dev.SetPackages(&[]jdownloader.DownloadPackage{{Uuid: &pkgId, Name: &name}})
+	dev.SetLinks(&[]jdownloader.DownloadLink{watchedLink(1, 10), watchedLink(2, 10)})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	ch, err := jdownloader.Watch(ctx, dev.Downloader(), jdownloader.WatchOptionInterval(10*time.Millisecond))
+	assert.NoError(t, err)
+
+	assert.NoError(t, dev.UpdateLink(1, func(l *jdownloader.DownloadLink) {
+		l.BytesLoaded = ptr(int64(50))
+	}))
+	ch1 := nextChange(t, ch)
+	assert.Equal(t, jdownloader.ChangeLinkProgress, ch1.Type)
+	assert.Equal(t, int64(1), ch1.Uuid)
+	assert.Equal(t, int64(0), *ch1.Previous.BytesLoaded)
+	assert.Equal(t, int64(50), *ch1.Link.BytesLoaded)
+
+	for _, id := range []int64{1, 2} {
+		assert.NoError(t, dev.UpdateLink(id, func(l *jdownloader.DownloadLink) {
+			l.BytesLoaded = ptr(int64(100))
+			l.Finished = ptr(true)
+			l.Status = ptr("Finished")
+		}))
+	}
+	types := make([]jdownloader.ChangeType, 0)
+	for len(types) < 7 {
+		types = append(types, nextChange(t, ch).Type)
+	}
+	assert.Contains(t, types, jdownloader.ChangeLinkFinished)
+	assert.Contains(t, types, jdownloader.ChangeLinkStatus)
+	assert.Equal(t, jdownloader.ChangePackageFinished, types[6])
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWatchFilterChanges(t *testing.T) {
+	dev := newMockDevice(t)
+	dev.SetLinks(&[]jdownloader.DownloadLink{watchedLink(1, 10)})
+
+	ch, err := jdownloader.Watch(t.Context(), dev.Downloader(),
+		jdownloader.WatchOptionInterval(10*time.Millisecond),
+		jdownloader.WatchOptionChanges(jdownloader.ChangeLinkAdded, jdownloader.ChangeLinkRemoved))
+	assert.NoError(t, err)
+	assert.NoError(t, dev.UpdateLink(1, func(l *jdownloader.DownloadLink) {
+		l.BytesLoaded = ptr(int64(50))
+	}))
+	dev.SetLinks(&[]jdownloader.DownloadLink{watchedLink(2, 10)})
+	c1, c2 := nextChange(t, ch), nextChange(t, ch)
+	assert.Equal(t, jdownloader.ChangeLinkAdded, c1.Type)
+	assert.Equal(t, int64(2), c1.Uuid)
+	assert.Equal(t, jdownloader.ChangeLinkRemoved, c2.Type)
+	assert.Equal(t, int64(1), c2.Uuid)
+}
+
+func TestWatchInvalidInterval(t *testing.T) {
+	dev := newMockDevice(t)
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, err := jdownloader.Watch(t.Context(), dev.Downloader(), jdownloader.WatchOptionInterval(interval))
+		assert.Error(t, err)
+	}
+}